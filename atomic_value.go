@@ -0,0 +1,30 @@
+package satomic
+
+import (
+	"context"
+)
+
+// AtomicValue is like Querier.Atomic() but also returns the value computed by the callback function.
+// The zero value is returned if the transaction or savepoint is rolled back or can't be committed or released.
+func AtomicValue[T any](q Querier, f func(context.Context, Querier) (T, error)) (T, *Error) {
+	var v T
+	if q == nil {
+		return v, newError(nil, ErrNilQuerier)
+	}
+	if f == nil {
+		return v, q.Atomic(nil)
+	}
+
+	if err := q.Atomic(func(ctx context.Context, q Querier) error {
+		val, cbErr := f(ctx, q)
+		if cbErr != nil {
+			return cbErr
+		}
+		v = val
+		return nil
+	}); err != nil {
+		var zero T
+		return zero, err
+	}
+	return v, nil
+}
@@ -0,0 +1,104 @@
+package satomic_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"testing"
+)
+
+import (
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+)
+
+import (
+	"github.com/dhui/satomic"
+	"github.com/dhui/satomic/satomictest"
+	"github.com/dhui/satomic/savepointers/mock"
+)
+
+func TestAtomicValue(t *testing.T) {
+	selectErr := errors.New("select error")
+	commitErr := errors.New("commit error")
+
+	testCases := []struct {
+		name          string
+		mocker        func(sqlmock.Sqlmock) sqlmock.Sqlmock
+		expectedValue int
+		expectedErr   *satomic.Error
+	}{
+		{name: "success", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectQuery("SELECT 1;").WillReturnRows(sqlmock.NewRows([]string{""}).AddRow(1))
+			m.ExpectCommit()
+			return m
+		}, expectedValue: 1, expectedErr: nil},
+		{name: "callback error", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectQuery("SELECT 1;").WillReturnError(selectErr)
+			m.ExpectRollback()
+			return m
+		}, expectedValue: 0, expectedErr: satomictest.NewError(selectErr, nil)},
+		{name: "commit error", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectQuery("SELECT 1;").WillReturnRows(sqlmock.NewRows([]string{""}).AddRow(1))
+			m.ExpectCommit().WillReturnError(commitErr)
+			return m
+		}, expectedValue: 0, expectedErr: satomictest.NewError(nil, commitErr)},
+	}
+
+	ctx := context.Background()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, _sqlmock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal("Error creating sqlmock:", err)
+			}
+			defer db.Close() // nolint:errcheck
+
+			_sqlmock = tc.mocker(_sqlmock)
+
+			q, err := satomic.NewQuerier(ctx, db, mock.NewSavepointer(io.Discard, true), sql.TxOptions{})
+			if err != nil {
+				t.Fatal("Error creating Querier:", err)
+			}
+			v, atomicErr := satomic.AtomicValue(q, func(ctx context.Context, q satomic.Querier) (int, error) {
+				var v int
+				err := q.QueryRowContext(ctx, "SELECT 1;").Scan(&v)
+				return v, err
+			})
+			if !satomictest.ErrsEq(atomicErr, tc.expectedErr) {
+				t.Errorf("Didn't get the expected error: %+v != %+v", atomicErr, tc.expectedErr)
+			}
+			if v != tc.expectedValue {
+				t.Errorf("Didn't get the expected value: %d != %d", v, tc.expectedValue)
+			}
+
+			if err := _sqlmock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestAtomicValueNilCallback(t *testing.T) {
+	expectedErr := satomictest.NewError(nil, satomic.ErrNilQuerier)
+	if v, err := satomic.AtomicValue[int](nil, nil); v != 0 || !satomictest.ErrsEq(err, expectedErr) {
+		t.Errorf("Didn't get the expected value and error: %d, %+v", v, err)
+	}
+
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatal("Error creating sqlmock:", err)
+	}
+	defer db.Close() // nolint:errcheck
+
+	q, err := satomic.NewQuerier(context.Background(), db, mock.NewSavepointer(io.Discard, true), sql.TxOptions{})
+	if err != nil {
+		t.Fatal("Error creating Querier:", err)
+	}
+	if v, err := satomic.AtomicValue[int](q, nil); v != 0 || err != nil {
+		t.Errorf("Didn't get the expected value and error: %d, %+v", v, err)
+	}
+}
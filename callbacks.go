@@ -0,0 +1,105 @@
+package satomic
+
+import (
+	"context"
+	"sync"
+)
+
+// callbacks holds the functions registered with OnCommit(), OnRollback() and OnCommitOutcomeUnknown() within a
+// single transaction or savepoint
+type callbacks struct {
+	mu         sync.Mutex
+	onCommit   []func(context.Context)
+	onRollback []func(context.Context, error)
+	onUnknown  []func(context.Context, error)
+}
+
+// promote moves the callbacks from a released savepoint to its parent savepoint or transaction
+func (c *callbacks) promote(child *callbacks) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	child.mu.Lock()
+	defer child.mu.Unlock()
+	c.onCommit = append(c.onCommit, child.onCommit...)
+	c.onRollback = append(c.onRollback, child.onRollback...)
+	c.onUnknown = append(c.onUnknown, child.onUnknown...)
+	child.onCommit, child.onRollback, child.onUnknown = nil, nil, nil
+}
+
+// commit calls the commit callbacks in the order that they were registered
+func (c *callbacks) commit(ctx context.Context) {
+	c.mu.Lock()
+	onCommit := c.onCommit
+	c.onCommit, c.onRollback, c.onUnknown = nil, nil, nil
+	c.mu.Unlock()
+	for _, f := range onCommit {
+		f(ctx)
+	}
+}
+
+// rollback calls the rollback callbacks in the order that they were registered and discards the commit callbacks
+func (c *callbacks) rollback(ctx context.Context, err error) {
+	c.mu.Lock()
+	onRollback := c.onRollback
+	c.onCommit, c.onRollback, c.onUnknown = nil, nil, nil
+	c.mu.Unlock()
+	for _, f := range onRollback {
+		f(ctx, err)
+	}
+}
+
+// unknown calls the commit outcome unknown callbacks in the order that they were registered and discards the commit
+// and rollback callbacks
+func (c *callbacks) unknown(ctx context.Context, err error) {
+	c.mu.Lock()
+	onUnknown := c.onUnknown
+	c.onCommit, c.onRollback, c.onUnknown = nil, nil, nil
+	c.mu.Unlock()
+	for _, f := range onUnknown {
+		f(ctx, err)
+	}
+}
+
+func (q *querier) OnCommit(f func(context.Context)) {
+	if q == nil || f == nil {
+		return
+	}
+	if q.scope == nil {
+		// Not in a transaction, so there's nothing to wait for
+		f(q.ctx)
+		return
+	}
+	if q.scope.closed.Load() {
+		// The scope's callbacks have already been called or promoted
+		return
+	}
+	q.scope.callbacks.mu.Lock()
+	defer q.scope.callbacks.mu.Unlock()
+	q.scope.callbacks.onCommit = append(q.scope.callbacks.onCommit, f)
+}
+
+func (q *querier) OnRollback(f func(context.Context, error)) {
+	if q == nil || f == nil || q.scope == nil {
+		return
+	}
+	if q.scope.closed.Load() {
+		// The scope's callbacks have already been called or promoted
+		return
+	}
+	q.scope.callbacks.mu.Lock()
+	defer q.scope.callbacks.mu.Unlock()
+	q.scope.callbacks.onRollback = append(q.scope.callbacks.onRollback, f)
+}
+
+func (q *querier) OnCommitOutcomeUnknown(f func(context.Context, error)) {
+	if q == nil || f == nil || q.scope == nil {
+		return
+	}
+	if q.scope.closed.Load() {
+		// The scope's callbacks have already been called or promoted
+		return
+	}
+	q.scope.callbacks.mu.Lock()
+	defer q.scope.callbacks.mu.Unlock()
+	q.scope.callbacks.onUnknown = append(q.scope.callbacks.onUnknown, f)
+}
@@ -0,0 +1,140 @@
+package satomic_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+import (
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+)
+
+import (
+	"github.com/dhui/satomic"
+	"github.com/dhui/satomic/savepointers/mock"
+)
+
+func TestQuerierCallbacks(t *testing.T) {
+	cbErr := errors.New("callback error")
+	commitErr := errors.New("commit error")
+
+	testCases := []struct {
+		name           string
+		mocker         func(sqlmock.Sqlmock) sqlmock.Sqlmock
+		outerErr       error
+		innerErr       error
+		expectedCalls  []string
+		expectedErrors []error
+	}{
+		{name: "commit", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectExec("RELEASE 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectCommit()
+			return m
+		}, expectedCalls: []string{"outer commit", "inner commit"}},
+		{name: "savepoint rolled back", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectExec("ROLLBACK TO 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectCommit()
+			return m
+		}, innerErr: cbErr, expectedCalls: []string{"inner rollback", "outer commit"},
+			expectedErrors: []error{cbErr}},
+		{name: "transaction rolled back", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectExec("RELEASE 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectRollback()
+			return m
+		}, outerErr: cbErr, expectedCalls: []string{"outer rollback", "inner rollback"},
+			expectedErrors: []error{cbErr, cbErr}},
+		{name: "commit error", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectExec("RELEASE 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectCommit().WillReturnError(commitErr)
+			return m
+		}, expectedCalls: []string{"outer rollback", "inner rollback"},
+			expectedErrors: []error{commitErr, commitErr}},
+	}
+
+	ctx := context.Background()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, _sqlmock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal("Error creating sqlmock:", err)
+			}
+			defer db.Close() // nolint:errcheck
+
+			_sqlmock = tc.mocker(_sqlmock)
+
+			q, err := satomic.NewQuerier(ctx, db, mock.NewSavepointer(io.Discard, true), sql.TxOptions{})
+			if err != nil {
+				t.Fatal("Error creating Querier:", err)
+			}
+
+			var calls []string
+			var errs []error
+			register := func(q satomic.Querier, name string) {
+				q.OnCommit(func(context.Context) { calls = append(calls, name+" commit") })
+				q.OnRollback(func(_ context.Context, err error) {
+					calls = append(calls, name+" rollback")
+					errs = append(errs, err)
+				})
+			}
+			q.Atomic(func(ctx context.Context, q satomic.Querier) error { // nolint:errcheck
+				register(q, "outer")
+				q.Atomic(func(ctx context.Context, q satomic.Querier) error { // nolint:errcheck
+					register(q, "inner")
+					if len(calls) != 0 {
+						t.Error("Callbacks called before the transaction completed:", calls)
+					}
+					return tc.innerErr
+				})
+				return tc.outerErr
+			})
+
+			if !reflect.DeepEqual(calls, tc.expectedCalls) {
+				t.Errorf("Didn't get the expected callback calls: %v != %v", calls, tc.expectedCalls)
+			}
+			if !reflect.DeepEqual(errs, tc.expectedErrors) {
+				t.Errorf("Didn't get the expected callback errors: %v != %v", errs, tc.expectedErrors)
+			}
+
+			if err := _sqlmock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestQuerierCallbacksNoTransaction(t *testing.T) {
+	db, _sqlmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal("Error creating sqlmock:", err)
+	}
+	defer db.Close() // nolint:errcheck
+
+	ctx := context.Background()
+	q, err := satomic.NewQuerier(ctx, db, mock.NewSavepointer(io.Discard, true), sql.TxOptions{})
+	if err != nil {
+		t.Fatal("Error creating Querier:", err)
+	}
+
+	committed := false
+	q.OnCommit(func(context.Context) { committed = true })
+	if !committed {
+		t.Error("Commit callback wasn't called immediately")
+	}
+	q.OnRollback(func(context.Context, error) { t.Error("Rollback callback shouldn't be called") })
+
+	if err := _sqlmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,69 @@
+package satomic
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+)
+
+import (
+	"github.com/dhui/satomic/savepointers"
+)
+
+// Classify returns the savepointers.ErrorClass of the given error using the ErrorClassifiers of the database drivers.
+// e.g. pqclassify.Classifier{} from github.com/dhui/satomic/savepointers/postgres/pqclassify Without an
+// ErrorClassifier, only lost connections are detected.
+//
+// If the error is a *Error, the callback function's error is classified first, followed by the Atomic error.
+func Classify(err error, classifiers ...savepointers.ErrorClassifier) savepointers.ErrorClass {
+	if err == nil {
+		return savepointers.ClassUnknown
+	}
+
+	var satomicErr *Error
+	if errors.As(err, &satomicErr) && satomicErr != nil {
+		if class := Classify(satomicErr.Err, classifiers...); class != savepointers.ClassUnknown {
+			return class
+		}
+		return Classify(satomicErr.Atomic, classifiers...)
+	}
+
+	for _, c := range classifiers {
+		if c == nil {
+			continue
+		}
+		if class := c.ClassifyError(err); class != savepointers.ClassUnknown {
+			return class
+		}
+	}
+
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) {
+		return savepointers.ClassConnectionLost
+	}
+	// The context's errors implement net.Error but are returned by database/sql when the context is done, e.g. by
+	// sql.Tx.Commit(), in which case the transaction is rolled back
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return savepointers.ClassUnknown
+	}
+	// Drivers may return the underlying network error when the connection is lost. e.g. github.com/lib/pq
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return savepointers.ClassConnectionLost
+	}
+	return savepointers.ClassUnknown
+}
+
+// IsTransient determines whether or not the error is likely to succeed if the transaction is retried.
+// i.e. serialization failures, deadlocks, and lock timeouts. The errors are classified with the ErrorClassifiers, so
+// IsTransient always returns false without one. See Classify()
+func IsTransient(err error, classifiers ...savepointers.ErrorClassifier) bool {
+	switch Classify(err, classifiers...) {
+	case savepointers.ClassSerializationFailure, savepointers.ClassDeadlock, savepointers.ClassLockTimeout:
+		return true
+	default:
+		return false
+	}
+}
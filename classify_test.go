@@ -0,0 +1,88 @@
+package satomic_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+)
+
+import (
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+import (
+	"github.com/dhui/satomic"
+	"github.com/dhui/satomic/satomictest"
+	"github.com/dhui/satomic/savepointers"
+	"github.com/dhui/satomic/savepointers/mssql/mssqlclassify"
+	"github.com/dhui/satomic/savepointers/mysql/mysqlclassify"
+	"github.com/dhui/satomic/savepointers/postgres/pqclassify"
+	"github.com/dhui/satomic/savepointers/sqlite/sqlite3classify"
+)
+
+func TestClassify(t *testing.T) {
+	classifiers := []savepointers.ErrorClassifier{pqclassify.Classifier{}, mysqlclassify.Classifier{},
+		mssqlclassify.Classifier{}, sqlite3classify.Classifier{}}
+
+	testCases := []struct {
+		name              string
+		err               error
+		noClassifier      bool
+		expectedClass     savepointers.ErrorClass
+		expectedTransient bool
+	}{
+		{name: "nil", err: nil, expectedClass: savepointers.ClassUnknown},
+		{name: "unknown", err: errors.New("unknown"), expectedClass: savepointers.ClassUnknown},
+		{name: "bad conn", err: driver.ErrBadConn, expectedClass: savepointers.ClassConnectionLost},
+		{name: "conn done", err: fmt.Errorf("wrapped: %w", sql.ErrConnDone),
+			expectedClass: savepointers.ClassConnectionLost},
+		{name: "net error", err: &net.OpError{Op: "read", Err: errors.New("connection reset by peer")},
+			expectedClass: savepointers.ClassConnectionLost},
+		{name: "context canceled", err: fmt.Errorf("wrapped: %w", context.Canceled),
+			expectedClass: savepointers.ClassUnknown},
+		{name: "context deadline exceeded", err: context.DeadlineExceeded, expectedClass: savepointers.ClassUnknown},
+		{name: "unexpected EOF", err: fmt.Errorf("wrapped: %w", io.ErrUnexpectedEOF),
+			expectedClass: savepointers.ClassConnectionLost},
+		{name: "postgres", err: &pq.Error{Code: "40P01"}, expectedClass: savepointers.ClassDeadlock,
+			expectedTransient: true},
+		{name: "mysql", err: &mysql.MySQLError{Number: 1205}, expectedClass: savepointers.ClassLockTimeout,
+			expectedTransient: true},
+		{name: "mssql", err: mssql.Error{Number: 2627}, expectedClass: savepointers.ClassUniqueViolation},
+		{name: "sqlite", err: sqlite3.Error{Code: sqlite3.ErrConstraint,
+			ExtendedCode: sqlite3.ErrConstraintNotNull}, expectedClass: savepointers.ClassNotNullViolation},
+		{name: "wrapped", err: fmt.Errorf("wrapped: %w", &pq.Error{Code: "40001"}),
+			expectedClass: savepointers.ClassSerializationFailure, expectedTransient: true},
+		{name: "satomic Error - Err", err: satomictest.NewError(&pq.Error{Code: "23503"}, driver.ErrBadConn),
+			expectedClass: savepointers.ClassForeignKeyViolation},
+		{name: "satomic Error - Atomic", err: satomictest.NewError(errors.New("unknown"), &pq.Error{Code: "40001"}),
+			expectedClass: savepointers.ClassSerializationFailure, expectedTransient: true},
+		{name: "nil satomic Error", err: (*satomic.Error)(nil), expectedClass: savepointers.ClassUnknown},
+		{name: "no classifier", err: &pq.Error{Code: "40001"}, noClassifier: true,
+			expectedClass: savepointers.ClassUnknown},
+		{name: "no classifier - bad conn", err: driver.ErrBadConn, noClassifier: true,
+			expectedClass: savepointers.ClassConnectionLost},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			classifiers := classifiers
+			if tc.noClassifier {
+				classifiers = nil
+			}
+			if class := satomic.Classify(tc.err, classifiers...); class != tc.expectedClass {
+				t.Errorf("Didn't get the expected class: %v != %v", class, tc.expectedClass)
+			}
+			if transient := satomic.IsTransient(tc.err, classifiers...); transient != tc.expectedTransient {
+				t.Errorf("Didn't get the expected transient value: %v != %v", transient, tc.expectedTransient)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package satomic
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SQLCommentOptions configures the comments added by WithSQLComments()
+type SQLCommentOptions struct {
+	// Traceparent returns the W3C traceparent of the trace in the context. e.g. satomicotel.Traceparent()
+	// If nil or if an empty string is returned, the traceparent tag is omitted.
+	Traceparent func(context.Context) string
+	// Tags returns additional tags to add to the comment. e.g. the route of the request being served.
+	// Tags with empty values are omitted and the label, depth and traceparent tags take precedence.
+	Tags func(context.Context) map[string]string
+}
+
+// sqlCommenter adds sqlcommenter comments to statements for WithSQLComments()
+type sqlCommenter struct {
+	opts SQLCommentOptions
+}
+
+// comment adds a comment with the tags to the query. Queries that already have a comment aren't changed.
+func (c *sqlCommenter) comment(ctx context.Context, query, label string, depth int) string {
+	if query == "" || strings.Contains(query, "/*") || strings.Contains(query, "--") {
+		return query
+	}
+	tags := map[string]string{}
+	if c.opts.Tags != nil {
+		for k, v := range c.opts.Tags(ctx) {
+			tags[k] = v
+		}
+	}
+	tags["label"] = label
+	tags["depth"] = strconv.Itoa(depth)
+	tags["traceparent"] = ""
+	if c.opts.Traceparent != nil {
+		tags["traceparent"] = c.opts.Traceparent(ctx)
+	}
+	comment := formatSQLComment(tags)
+	if comment == "" {
+		return query
+	}
+
+	// The comment goes before the statement's terminating semicolon so that it's part of the statement
+	trimmed := strings.TrimRight(query, " \t\r\n")
+	if strings.HasSuffix(trimmed, ";") {
+		return trimmed[:len(trimmed)-1] + " " + comment + ";"
+	}
+	return trimmed + " " + comment
+}
+
+// intercept adds a comment to the statements run by the Querier
+func (c *sqlCommenter) intercept(ctx context.Context, info StatementInfo, next StatementRunner) error {
+	return next(ctx, c.comment(ctx, info.Query, info.Label, info.Depth), info.Args...)
+}
+
+// formatSQLComment formats the tags as a sqlcommenter comment. e.g. /*depth='1',label='checkout'*/
+// Tags are sorted by key and tags with empty values are omitted. Keys and values are URL encoded, so they can't end
+// the comment or contain quotes.
+//
+// https://google.github.io/sqlcommenter/spec/
+func formatSQLComment(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k, v := range tags {
+		if k != "" && v != "" {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	sb.WriteString("/*")
+	for i, k := range keys {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(url.PathEscape(k))
+		sb.WriteString("='")
+		// url.PathEscape() already escapes quotes, but the spec also requires them to be escaped with a backslash
+		sb.WriteString(strings.ReplaceAll(url.PathEscape(tags[k]), "'", `\'`))
+		sb.WriteByte('\'')
+	}
+	sb.WriteString("*/")
+	return sb.String()
+}
+
+// comment adds a comment to the statement with WithSQLComments()
+func (q *querier) comment(ctx context.Context, stmt string) string {
+	if q.commenter == nil {
+		return stmt
+	}
+	return q.commenter.comment(ctx, stmt, q.label, q.Depth())
+}
@@ -0,0 +1,104 @@
+package satomic_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"testing"
+)
+
+import (
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+)
+
+import (
+	"github.com/dhui/satomic"
+	"github.com/dhui/satomic/savepointers/mock"
+)
+
+type traceparentKey struct{}
+
+func TestQuerierSQLComments(t *testing.T) {
+	db, _sqlmock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal("Error creating sqlmock:", err)
+	}
+	defer db.Close() // nolint:errcheck
+
+	cbErr := errors.New("callback error")
+	injectedLabel := `x'*/; DROP TABLE t; --`
+	injectedLabelTag := "label='checkout%2Fx%27%2A%2F%3B%20DROP%20TABLE%20t%3B%20--'"
+
+	_sqlmock.ExpectExec("UPDATE 1 /*depth='0',route='%2Fcheckout'*/;").WillReturnResult(sqlmock.NewResult(0, 1))
+	_sqlmock.ExpectBegin()
+	_sqlmock.ExpectExec("UPDATE 2 /*depth='1',label='checkout',route='%2Fcheckout',traceparent='tx'*/;").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	_sqlmock.ExpectExec("UPDATE 3 /* already commented */").WillReturnResult(sqlmock.NewResult(0, 1))
+	_sqlmock.ExpectExec("SAVEPOINT 1 /*depth='2'," + injectedLabelTag + ",route='%2Fcheckout',traceparent='tx'*/;").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectExec("ROLLBACK TO 1 /*depth='2'," + injectedLabelTag + ",route='%2Fcheckout',traceparent='tx'*/;").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectExec("SAVEPOINT 2 /*depth='2',label='checkout',route='%2Fcheckout',traceparent='tx'*/;").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectQuery("SELECT 4 /*depth='2',label='checkout',route='%2Fcheckout',traceparent='sp'*/").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	_sqlmock.ExpectExec("RELEASE 2 /*depth='2',label='checkout',route='%2Fcheckout',traceparent='tx'*/;").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectCommit()
+
+	ctx := context.Background()
+	q, err := satomic.NewQuerier(ctx, db, mock.NewSavepointer(io.Discard, true), sql.TxOptions{},
+		satomic.WithSQLComments(satomic.SQLCommentOptions{
+			Traceparent: func(ctx context.Context) string {
+				traceparent, _ := ctx.Value(traceparentKey{}).(string)
+				return traceparent
+			},
+			Tags: func(context.Context) map[string]string {
+				return map[string]string{"route": "/checkout", "depth": "overridden", "empty": ""}
+			},
+		}),
+		satomic.WithHooks(traceparentHooks{}))
+	if err != nil {
+		t.Fatal("Error creating Querier:", err)
+	}
+
+	if _, err := q.ExecContext(ctx, "UPDATE 1;"); err != nil {
+		t.Error(err)
+	}
+	if err := q.AtomicNamed("checkout", func(ctx context.Context, q satomic.Querier) error {
+		if _, err := q.ExecContext(ctx, "UPDATE 2; \n"); err != nil {
+			return err
+		}
+		if _, err := q.ExecContext(ctx, "UPDATE 3 /* already commented */"); err != nil {
+			return err
+		}
+		if err := q.AtomicNamed(injectedLabel, func(context.Context, satomic.Querier) error {
+			return cbErr
+		}); !errors.Is(err, cbErr) {
+			t.Errorf("Didn't get the expected error: %+v doesn't wrap %v", err, cbErr)
+		}
+		if err := q.Atomic(func(ctx context.Context, q satomic.Querier) error {
+			var id int
+			return q.QueryRowContext(context.WithValue(ctx, traceparentKey{}, "sp"), "SELECT 4").Scan(&id)
+		}); err != nil {
+			return err
+		}
+		return nil
+	}); err != nil {
+		t.Error(err)
+	}
+
+	if err := _sqlmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+// traceparentHooks adds a traceparent to the context of transactions
+type traceparentHooks struct {
+	satomic.NoopHooks
+}
+
+func (traceparentHooks) BeforeBegin(ctx context.Context, _ satomic.HookInfo) context.Context {
+	return context.WithValue(ctx, traceparentKey{}, "tx")
+}
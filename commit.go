@@ -0,0 +1,64 @@
+package satomic
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+import (
+	"github.com/dhui/satomic/savepointers"
+)
+
+// ErrCommitOutcomeUnknown is the canonical error value when the connection is lost while committing a transaction,
+// so the transaction may or may not have been committed. It's returned in Error.Atomic along with the commit error.
+// Non-idempotent work shouldn't be retried unless the outcome is checked first. See WithCommitVerifier()
+// The OnCommitOutcomeUnknown() functions are called instead of the OnCommit() and OnRollback() functions.
+var ErrCommitOutcomeUnknown = errors.New("Transaction commit outcome is unknown")
+
+// CommitVerifier provides an interface for determining the outcome of a transaction whose commit failed because the
+// connection was lost. e.g. github.com/dhui/satomic/savepointers/postgres
+type CommitVerifier interface {
+	// TxID returns the identifier of the transaction. It's called as soon as the transaction is begun.
+	TxID(ctx context.Context, tx *sql.Tx) (int64, error)
+	// Committed determines whether or not the transaction with the given identifier was committed. The DB is used
+	// so that another connection is used. An error is returned if the outcome can't be determined.
+	// e.g. the transaction is still in progress
+	Committed(ctx context.Context, db *sql.DB, txID int64) (bool, error)
+}
+
+// recordTxID records the identifier of the newly begun transaction for the CommitVerifier. The transaction is rolled
+// back if its identifier can't be recorded.
+func (q *querier) recordTxID(tx *sql.Tx) (*sql.Tx, error) {
+	txID, err := q.commitVerifier.TxID(q.ctx, tx)
+	if err != nil {
+		tx.Rollback() // nolint:errcheck
+		// The transaction won't be used, so release anything registered for it. e.g. by a TxCreator
+		q.scope.callbacks.rollback(q.ctx, err)
+		return nil, err
+	}
+	q.scope.txID = txID
+	q.scope.verifiable = true
+	return tx, nil
+}
+
+// commitOutcome returns the error to report for a failed commit or nil if the transaction was committed anyways.
+// If the connection was lost, the transaction is checked with the CommitVerifier and ErrCommitOutcomeUnknown is
+// returned if it can't be checked.
+func (q *querier) commitOutcome(commitErr error) error {
+	if Classify(commitErr, q.classifier) != savepointers.ClassConnectionLost {
+		return commitErr
+	}
+	if q.commitVerifier == nil || !q.scope.verifiable {
+		return errors.Join(ErrCommitOutcomeUnknown, commitErr)
+	}
+	committed, err := q.commitVerifier.Committed(q.ctx, q.db, q.scope.txID)
+	switch {
+	case err != nil:
+		return errors.Join(ErrCommitOutcomeUnknown, commitErr, err)
+	case committed:
+		return nil
+	default:
+		return commitErr
+	}
+}
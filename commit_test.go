@@ -0,0 +1,159 @@
+package satomic_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+)
+
+import (
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+)
+
+import (
+	"github.com/dhui/satomic"
+	"github.com/dhui/satomic/savepointers/mock"
+)
+
+type mockCommitVerifier struct {
+	txID       int64
+	txIDErr    error
+	committed  bool
+	err        error
+	verifiedID int64
+}
+
+func (v *mockCommitVerifier) TxID(context.Context, *sql.Tx) (int64, error) {
+	return v.txID, v.txIDErr
+}
+
+func (v *mockCommitVerifier) Committed(_ context.Context, _ *sql.DB, txID int64) (bool, error) {
+	v.verifiedID = txID
+	return v.committed, v.err
+}
+
+func TestQuerierAtomicCommitOutcome(t *testing.T) {
+	commitErr := errors.New("commit error")
+	verifyErr := errors.New("verify error")
+
+	testCases := []struct {
+		name               string
+		verifier           *mockCommitVerifier
+		mocker             func(sqlmock.Sqlmock) sqlmock.Sqlmock
+		expectedErrs       []error
+		expectedPhase      satomic.Phase
+		expectedCallback   string
+		expectedVerifiedID int64
+	}{
+		{name: "commit error", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectCommit().WillReturnError(commitErr)
+			return m
+		}, expectedErrs: []error{commitErr}, expectedPhase: satomic.PhaseCommit, expectedCallback: "rollback"},
+		{name: "connection lost", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectCommit().WillReturnError(driver.ErrBadConn)
+			return m
+		}, expectedErrs: []error{satomic.ErrCommitOutcomeUnknown, driver.ErrBadConn},
+			expectedPhase: satomic.PhaseCommit, expectedCallback: "unknown"},
+		{name: "context done", verifier: &mockCommitVerifier{txID: 7},
+			mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+				m.ExpectBegin()
+				m.ExpectCommit().WillReturnError(context.DeadlineExceeded)
+				return m
+			}, expectedErrs: []error{context.DeadlineExceeded}, expectedPhase: satomic.PhaseCommit,
+			expectedCallback: "rollback"},
+		{name: "verifier - commit error", verifier: &mockCommitVerifier{txID: 7},
+			mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+				m.ExpectBegin()
+				m.ExpectCommit().WillReturnError(commitErr)
+				return m
+			}, expectedErrs: []error{commitErr}, expectedPhase: satomic.PhaseCommit, expectedCallback: "rollback"},
+		{name: "verifier - committed", verifier: &mockCommitVerifier{txID: 7, committed: true},
+			mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+				m.ExpectBegin()
+				m.ExpectCommit().WillReturnError(io.ErrUnexpectedEOF)
+				return m
+			}, expectedCallback: "commit", expectedVerifiedID: 7},
+		{name: "verifier - aborted", verifier: &mockCommitVerifier{txID: 7},
+			mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+				m.ExpectBegin()
+				m.ExpectCommit().WillReturnError(driver.ErrBadConn)
+				return m
+			}, expectedErrs: []error{driver.ErrBadConn}, expectedPhase: satomic.PhaseCommit, expectedVerifiedID: 7,
+			expectedCallback: "rollback"},
+		{name: "verifier - unknown", verifier: &mockCommitVerifier{txID: 7, err: verifyErr},
+			mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+				m.ExpectBegin()
+				m.ExpectCommit().WillReturnError(driver.ErrBadConn)
+				return m
+			}, expectedErrs: []error{satomic.ErrCommitOutcomeUnknown, driver.ErrBadConn, verifyErr},
+			expectedPhase: satomic.PhaseCommit, expectedVerifiedID: 7, expectedCallback: "unknown"},
+		{name: "verifier - TxID error", verifier: &mockCommitVerifier{txIDErr: verifyErr},
+			mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+				m.ExpectBegin()
+				m.ExpectRollback()
+				return m
+			}, expectedErrs: []error{verifyErr}, expectedPhase: satomic.PhaseBegin},
+	}
+
+	ctx := context.Background()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, _sqlmock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal("Error creating sqlmock:", err)
+			}
+			defer db.Close() // nolint:errcheck
+
+			_sqlmock = tc.mocker(_sqlmock)
+
+			var opts []satomic.Option
+			if tc.verifier != nil {
+				opts = append(opts, satomic.WithCommitVerifier(tc.verifier))
+			}
+			q, err := satomic.NewQuerier(ctx, db, mock.NewSavepointer(io.Discard, true), sql.TxOptions{}, opts...)
+			if err != nil {
+				t.Fatal("Error creating Querier:", err)
+			}
+
+			callback := ""
+			atomicErr := q.Atomic(func(ctx context.Context, q satomic.Querier) error {
+				q.OnCommit(func(context.Context) { callback += "commit" })
+				q.OnRollback(func(context.Context, error) { callback += "rollback" })
+				q.OnCommitOutcomeUnknown(func(_ context.Context, err error) {
+					if !errors.Is(err, satomic.ErrCommitOutcomeUnknown) {
+						t.Errorf("Didn't get the expected error: %v doesn't wrap %v", err, satomic.ErrCommitOutcomeUnknown)
+					}
+					callback += "unknown"
+				})
+				return nil
+			})
+			if len(tc.expectedErrs) == 0 && atomicErr != nil {
+				t.Error("Unexpected error:", atomicErr)
+			}
+			for _, expectedErr := range tc.expectedErrs {
+				if !errors.Is(atomicErr, expectedErr) {
+					t.Errorf("Didn't get the expected error: %+v doesn't wrap %v", atomicErr, expectedErr)
+				}
+			}
+			if atomicErr != nil && atomicErr.Phase != tc.expectedPhase {
+				t.Errorf("Didn't get the expected phase: %v != %v", atomicErr.Phase, tc.expectedPhase)
+			}
+			if callback != tc.expectedCallback {
+				t.Errorf("Didn't get the expected callback: %q != %q", callback, tc.expectedCallback)
+			}
+			if tc.verifier != nil && tc.verifier.verifiedID != tc.expectedVerifiedID {
+				t.Errorf("Didn't verify the expected transaction: %d != %d", tc.verifier.verifiedID,
+					tc.expectedVerifiedID)
+			}
+
+			if err := _sqlmock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
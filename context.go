@@ -0,0 +1,28 @@
+package satomic
+
+import (
+	"context"
+)
+
+// querierContextKey is the context key for the Querier stored by WithQuerier()
+type querierContextKey struct{}
+
+// WithQuerier returns a copy of the context that carries the given Querier. Atomic() calls the callback function
+// with a context that carries the callback function's Querier, so functions that only receive the context can run
+// their SQL statements in the current transaction or savepoint.
+func WithQuerier(ctx context.Context, q Querier) context.Context {
+	return context.WithValue(ctx, querierContextKey{}, q)
+}
+
+// QuerierFromContext returns the Querier carried by the context. If the context doesn't carry a Querier, e.g. it's
+// not from within an Atomic() callback function, the fallback Querier is returned. Usually, the fallback is the
+// root Querier created by NewQuerier().
+func QuerierFromContext(ctx context.Context, fallback Querier) Querier {
+	if ctx == nil {
+		return fallback
+	}
+	if q, ok := ctx.Value(querierContextKey{}).(Querier); ok && q != nil {
+		return q
+	}
+	return fallback
+}
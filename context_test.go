@@ -0,0 +1,75 @@
+package satomic_test
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"testing"
+)
+
+import (
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+)
+
+import (
+	"github.com/dhui/satomic"
+	"github.com/dhui/satomic/savepointers/mock"
+)
+
+func TestQuerierFromContext(t *testing.T) {
+	db, _sqlmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal("Error creating sqlmock:", err)
+	}
+	defer db.Close() // nolint:errcheck
+
+	_sqlmock.ExpectBegin()
+	_sqlmock.ExpectExec("UPDATE 1;").WillReturnResult(sqlmock.NewResult(0, 1))
+	_sqlmock.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectExec("UPDATE 2;").WillReturnResult(sqlmock.NewResult(0, 1))
+	_sqlmock.ExpectExec("RELEASE 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectCommit()
+
+	ctx := context.Background()
+	root, err := satomic.NewQuerier(ctx, db, mock.NewSavepointer(io.Discard, true), sql.TxOptions{})
+	if err != nil {
+		t.Fatal("Error creating Querier:", err)
+	}
+
+	if q := satomic.QuerierFromContext(ctx, root); q != root {
+		t.Error("Didn't get the fallback Querier from a context without a Querier")
+	}
+	if q := satomic.QuerierFromContext(satomic.WithQuerier(ctx, root), nil); q != root {
+		t.Error("Didn't get the Querier stored with WithQuerier()")
+	}
+
+	// update only has access to the context
+	update := func(ctx context.Context, query string) error {
+		_, err := satomic.QuerierFromContext(ctx, root).ExecContext(ctx, query)
+		return err
+	}
+
+	if err := root.Atomic(func(ctx context.Context, q satomic.Querier) error {
+		if fromCtx := satomic.QuerierFromContext(ctx, root); fromCtx != q {
+			t.Error("The callback function's context doesn't carry the callback function's Querier")
+		}
+		if err := update(ctx, "UPDATE 1;"); err != nil {
+			return err
+		}
+		if err := satomic.QuerierFromContext(ctx, root).Atomic(func(ctx context.Context, q satomic.Querier) error {
+			if fromCtx := satomic.QuerierFromContext(ctx, root); fromCtx != q {
+				t.Error("The nested callback function's context doesn't carry the nested Querier")
+			}
+			return update(ctx, "UPDATE 2;")
+		}); err != nil {
+			return err
+		}
+		return nil
+	}); err != nil {
+		t.Error(err)
+	}
+
+	if err := _sqlmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -4,6 +4,48 @@ import (
 	"fmt"
 )
 
+// Phase is the step of Querier.Atomic() that an Error occurred in
+type Phase int
+
+const (
+	// PhaseValidation is used for errors from checking the Querier and options before the transaction or
+	// savepoint is created
+	PhaseValidation Phase = iota
+	// PhaseBegin is used for errors beginning the transaction
+	PhaseBegin
+	// PhaseSavepointCreate is used for errors creating the savepoint
+	PhaseSavepointCreate
+	// PhaseCallback is used for errors returned by the callback function, including panics
+	PhaseCallback
+	// PhaseRollback is used for errors rolling back the transaction or savepoint
+	PhaseRollback
+	// PhaseRelease is used for errors releasing the savepoint
+	PhaseRelease
+	// PhaseCommit is used for errors committing the transaction
+	PhaseCommit
+)
+
+func (p Phase) String() string {
+	switch p {
+	case PhaseValidation:
+		return "validation"
+	case PhaseBegin:
+		return "begin"
+	case PhaseSavepointCreate:
+		return "savepoint create"
+	case PhaseCallback:
+		return "callback"
+	case PhaseRollback:
+		return "rollback"
+	case PhaseRelease:
+		return "release"
+	case PhaseCommit:
+		return "commit"
+	default:
+		return "unknown"
+	}
+}
+
 // Error implements the error interface and is used to differentiate between Querier.Atomic() errors
 // and Querier.Atomic() callback function errors
 type Error struct {
@@ -12,6 +54,14 @@ type Error struct {
 	// Atomic is an error from within Querier.Atomic()'s implementation.
 	// Usually such an error is the result of an improperly configured/created Querier or a DB error.
 	Atomic error
+	// Phase is the step of Querier.Atomic() that failed
+	Phase Phase
+	// Depth is the Depth() of the callback function's Querier, i.e. 1 for a transaction and more for a savepoint.
+	// 0 if the error occurred before the transaction or savepoint was set up.
+	Depth int
+	// Label is the label path of the callback function's Querier. See Querier.Label()
+	// Empty if the error occurred before the transaction or savepoint was set up.
+	Label string
 }
 
 func (e *Error) Error() string {
@@ -29,6 +79,49 @@ func (e *Error) Error() string {
 	return fmt.Sprintf("Err: %q Atomic: %q", e.Err.Error(), e.Atomic.Error())
 }
 
+// PanicError is used as Error.Err when the callback function passed to Querier.Atomic() panics
+type PanicError struct {
+	// Value is the value that the callback function panicked with
+	Value interface{}
+	// Stack is the stack trace of the goroutine that panicked, as formatted by runtime/debug.Stack()
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	if e == nil {
+		return ""
+	}
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the panic value if it's an error
+func (e *PanicError) Unwrap() error {
+	if e == nil {
+		return nil
+	}
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Unwrap returns the non-nil errors out of Err and Atomic so that errors.Is() and errors.As() check both
+func (e *Error) Unwrap() []error {
+	if e == nil {
+		return nil
+	}
+	errs := make([]error, 0, 2)
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	if e.Atomic != nil {
+		errs = append(errs, e.Atomic)
+	}
+	return errs
+}
+
 func newError(err, dbErr error) *Error {
 	return &Error{Err: err, Atomic: dbErr}
 }
+
+func newPhaseError(phase Phase, err, dbErr error) *Error {
+	return &Error{Err: err, Atomic: dbErr, Phase: phase}
+}
@@ -1,10 +1,15 @@
 package satomic_test
 
 import (
+	"database/sql"
 	"errors"
 	"testing"
 )
 
+import (
+	"github.com/lib/pq"
+)
+
 import (
 	"github.com/dhui/satomic"
 	"github.com/dhui/satomic/satomictest"
@@ -35,3 +40,69 @@ func TestError(t *testing.T) {
 		})
 	}
 }
+
+func TestPanicError(t *testing.T) {
+	valueErr := errors.New("value error")
+
+	testCases := []struct {
+		name              string
+		err               *satomic.PanicError
+		expectedStr       string
+		expectedUnwrapped error
+	}{
+		{name: "nil PanicError", err: nil, expectedStr: "", expectedUnwrapped: nil},
+		{name: "non-error value", err: &satomic.PanicError{Value: "whoa!"}, expectedStr: "panic: whoa!",
+			expectedUnwrapped: nil},
+		{name: "error value", err: &satomic.PanicError{Value: valueErr}, expectedStr: "panic: value error",
+			expectedUnwrapped: valueErr},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if str := tc.err.Error(); str != tc.expectedStr {
+				t.Error("Didn't get expected Error string:", str, "!=", tc.expectedStr)
+			}
+			if err := tc.err.Unwrap(); err != tc.expectedUnwrapped {
+				t.Errorf("Didn't get expected unwrapped error: %+v != %+v", err, tc.expectedUnwrapped)
+			}
+		})
+	}
+}
+
+func TestErrorUnwrap(t *testing.T) {
+	pqErr := &pq.Error{Code: "40001"}
+
+	testCases := []struct {
+		name        string
+		err         *satomic.Error
+		target      error
+		expectedIs  bool
+		expectedAsV bool
+	}{
+		{name: "nil Error", err: nil, target: sql.ErrNoRows, expectedIs: false},
+		{name: "Err", err: satomictest.NewError(sql.ErrNoRows, nil), target: sql.ErrNoRows, expectedIs: true},
+		{name: "Atomic", err: satomictest.NewError(nil, sql.ErrNoRows), target: sql.ErrNoRows, expectedIs: true},
+		{name: "joined Atomic", err: satomictest.NewError(nil, errors.Join(satomic.ErrRollbackOnly, sql.ErrTxDone)),
+			target: sql.ErrTxDone, expectedIs: true},
+		{name: "neither", err: satomictest.NewError(errors.New("err"), errors.New("atomic")), target: sql.ErrNoRows,
+			expectedIs: false},
+		{name: "As", err: satomictest.NewError(errors.New("err"), pqErr), target: pqErr, expectedIs: true,
+			expectedAsV: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var err error
+			if tc.err != nil {
+				err = tc.err
+			}
+			if is := errors.Is(err, tc.target); is != tc.expectedIs {
+				t.Errorf("Didn't get the expected errors.Is(): %v != %v", is, tc.expectedIs)
+			}
+			var target *pq.Error
+			if as := errors.As(err, &target); as != tc.expectedAsV {
+				t.Errorf("Didn't get the expected errors.As(): %v != %v", as, tc.expectedAsV)
+			}
+		})
+	}
+}
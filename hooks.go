@@ -0,0 +1,185 @@
+package satomic
+
+import (
+	"context"
+	"time"
+)
+
+// HookInfo describes the transaction or savepoint that a Hooks method is called for
+type HookInfo struct {
+	// Depth is 1 for a transaction and 1 more for each savepoint. See Querier.Depth()
+	Depth int
+	// SavepointName is the name of the savepoint or "" for a transaction
+	SavepointName string
+	// Label identifies the transaction or savepoint. See AtomicOptions.Label
+	Label string
+	// Statement is the SQL statement run for the operation. e.g. the Savepointer's SAVEPOINT statement or
+	// "COMMIT" for a transaction. "" if no statement was run. e.g. the transaction wasn't begun with WithLazyBegin()
+	Statement string
+	// Duration is how long the operation took. Only set for the After* and OnRelease methods.
+	Duration time.Duration
+	// Elapsed is how long the transaction or savepoint has been running, starting from BeforeBegin() or
+	// BeforeSavepoint(). Only set for the AfterCommit(), AfterRollback() and OnRelease() methods.
+	Elapsed time.Duration
+	// Err is the error returned by the operation. Only set for the After* and OnRelease methods.
+	Err error
+	// Cause is the error that caused the rollback. Only set for the BeforeRollback() and AfterRollback() methods.
+	Cause error
+	// CausePhase is the phase that the Cause occurred in. e.g. PhaseCallback for the callback function's error or
+	// PhaseCommit for a rollback-only transaction. Only set along with Cause.
+	CausePhase Phase
+}
+
+// Hooks are called throughout the lifecycle of the transactions and savepoints created by Atomic(). e.g. for
+// logging, metrics or tracing. Embed NoopHooks to only implement some of the methods.
+//
+// BeforeBegin() and BeforeSavepoint() are called as soon as Atomic() starts a transaction or savepoint and the
+// returned context is used by the transaction or savepoint, including by the callback function. Each is followed
+// by exactly one call to AfterCommit(), AfterRollback() or OnRelease() once the transaction or savepoint ends, even
+// if no statement is run. Joining a transaction doesn't call any hooks. See PropagationRequired
+type Hooks interface {
+	// BeforeBegin is called before the transaction is begun
+	BeforeBegin(ctx context.Context, info HookInfo) context.Context
+	// AfterBegin is called after the transaction is begun or fails to begin
+	AfterBegin(ctx context.Context, info HookInfo)
+	// BeforeSavepoint is called before the savepoint is created
+	BeforeSavepoint(ctx context.Context, info HookInfo) context.Context
+	// AfterSavepoint is called after the savepoint is created or fails to be created
+	AfterSavepoint(ctx context.Context, info HookInfo)
+	// BeforeCommit is called before the transaction is committed. Returning an error rolls back the transaction
+	// instead and Atomic() returns the error in Error.Atomic.
+	BeforeCommit(ctx context.Context, info HookInfo) error
+	// AfterCommit is called after the transaction is committed or fails to commit
+	AfterCommit(ctx context.Context, info HookInfo)
+	// BeforeRollback is called before the transaction or savepoint is rolled back
+	BeforeRollback(ctx context.Context, info HookInfo)
+	// AfterRollback is called after the transaction or savepoint is rolled back or fails to roll back
+	AfterRollback(ctx context.Context, info HookInfo)
+	// OnRelease is called after the savepoint is released or fails to be released
+	OnRelease(ctx context.Context, info HookInfo)
+}
+
+// NoopHooks implements the Hooks interface without doing anything
+type NoopHooks struct{}
+
+// BeforeBegin returns the given context
+func (NoopHooks) BeforeBegin(ctx context.Context, _ HookInfo) context.Context { return ctx }
+
+// AfterBegin does nothing
+func (NoopHooks) AfterBegin(context.Context, HookInfo) {}
+
+// BeforeSavepoint returns the given context
+func (NoopHooks) BeforeSavepoint(ctx context.Context, _ HookInfo) context.Context { return ctx }
+
+// AfterSavepoint does nothing
+func (NoopHooks) AfterSavepoint(context.Context, HookInfo) {}
+
+// BeforeCommit returns nil
+func (NoopHooks) BeforeCommit(context.Context, HookInfo) error { return nil }
+
+// AfterCommit does nothing
+func (NoopHooks) AfterCommit(context.Context, HookInfo) {}
+
+// BeforeRollback does nothing
+func (NoopHooks) BeforeRollback(context.Context, HookInfo) {}
+
+// AfterRollback does nothing
+func (NoopHooks) AfterRollback(context.Context, HookInfo) {}
+
+// OnRelease does nothing
+func (NoopHooks) OnRelease(context.Context, HookInfo) {}
+
+// composedHooks calls each of the Hooks in order. The After* and OnRelease methods are called in reverse order so
+// that the Hooks are nested.
+type composedHooks []Hooks
+
+// ComposeHooks combines the given Hooks into a single Hooks. The Before* methods are called in order and the After*
+// and OnRelease methods are called in reverse order. BeforeCommit() stops at the first error. nil Hooks are ignored.
+func ComposeHooks(hooks ...Hooks) Hooks {
+	composed := make(composedHooks, 0, len(hooks))
+	for _, h := range hooks {
+		switch h := h.(type) {
+		case nil:
+		case composedHooks:
+			composed = append(composed, h...)
+		default:
+			composed = append(composed, h)
+		}
+	}
+	if len(composed) == 1 {
+		return composed[0]
+	}
+	return composed
+}
+
+func (c composedHooks) BeforeBegin(ctx context.Context, info HookInfo) context.Context {
+	for _, h := range c {
+		ctx = h.BeforeBegin(ctx, info)
+	}
+	return ctx
+}
+
+func (c composedHooks) AfterBegin(ctx context.Context, info HookInfo) {
+	for i := len(c) - 1; i >= 0; i-- {
+		c[i].AfterBegin(ctx, info)
+	}
+}
+
+func (c composedHooks) BeforeSavepoint(ctx context.Context, info HookInfo) context.Context {
+	for _, h := range c {
+		ctx = h.BeforeSavepoint(ctx, info)
+	}
+	return ctx
+}
+
+func (c composedHooks) AfterSavepoint(ctx context.Context, info HookInfo) {
+	for i := len(c) - 1; i >= 0; i-- {
+		c[i].AfterSavepoint(ctx, info)
+	}
+}
+
+func (c composedHooks) BeforeCommit(ctx context.Context, info HookInfo) error {
+	for _, h := range c {
+		if err := h.BeforeCommit(ctx, info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c composedHooks) AfterCommit(ctx context.Context, info HookInfo) {
+	for i := len(c) - 1; i >= 0; i-- {
+		c[i].AfterCommit(ctx, info)
+	}
+}
+
+func (c composedHooks) BeforeRollback(ctx context.Context, info HookInfo) {
+	for _, h := range c {
+		h.BeforeRollback(ctx, info)
+	}
+}
+
+func (c composedHooks) AfterRollback(ctx context.Context, info HookInfo) {
+	for i := len(c) - 1; i >= 0; i-- {
+		c[i].AfterRollback(ctx, info)
+	}
+}
+
+func (c composedHooks) OnRelease(ctx context.Context, info HookInfo) {
+	for i := len(c) - 1; i >= 0; i-- {
+		c[i].OnRelease(ctx, info)
+	}
+}
+
+// getHooks returns the Querier's Hooks or NoopHooks if it doesn't have any
+func (q *querier) getHooks() Hooks {
+	if q.hooks == nil {
+		return NoopHooks{}
+	}
+	return q.hooks
+}
+
+// hookInfo returns the HookInfo for q's transaction or savepoint
+func (q *querier) hookInfo(stmt string) HookInfo {
+	return HookInfo{Depth: q.Depth(), SavepointName: q.savepointName, Label: q.label, Statement: stmt}
+}
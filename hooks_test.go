@@ -0,0 +1,298 @@
+package satomic_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+import (
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+)
+
+import (
+	"github.com/dhui/satomic"
+	"github.com/dhui/satomic/savepointers/mock"
+)
+
+type ctxKey string
+
+// recordingHooks records the Hooks calls as "<name><method> <depth> <label> <statement>" with any error or cause
+type recordingHooks struct {
+	mu        sync.Mutex
+	name      string
+	calls     *[]string
+	commitErr error
+}
+
+func (h *recordingHooks) record(method string, info satomic.HookInfo) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	call := fmt.Sprintf("%s%s %d %s %s", h.name, method, info.Depth, info.Label, info.Statement)
+	if info.Err != nil {
+		call += " err=" + info.Err.Error()
+	}
+	if info.Cause != nil {
+		call += " cause=" + info.Cause.Error()
+	}
+	*h.calls = append(*h.calls, call)
+}
+
+func (h *recordingHooks) BeforeBegin(ctx context.Context, info satomic.HookInfo) context.Context {
+	h.record("BeforeBegin", info)
+	return context.WithValue(ctx, ctxKey(h.name+"BeforeBegin"), true)
+}
+
+func (h *recordingHooks) AfterBegin(_ context.Context, info satomic.HookInfo) {
+	h.record("AfterBegin", info)
+}
+
+func (h *recordingHooks) BeforeSavepoint(ctx context.Context, info satomic.HookInfo) context.Context {
+	h.record("BeforeSavepoint", info)
+	return context.WithValue(ctx, ctxKey(h.name+"BeforeSavepoint"), true)
+}
+
+func (h *recordingHooks) AfterSavepoint(_ context.Context, info satomic.HookInfo) {
+	h.record("AfterSavepoint", info)
+}
+
+func (h *recordingHooks) BeforeCommit(_ context.Context, info satomic.HookInfo) error {
+	h.record("BeforeCommit", info)
+	return h.commitErr
+}
+
+func (h *recordingHooks) AfterCommit(_ context.Context, info satomic.HookInfo) {
+	h.record("AfterCommit", info)
+}
+
+func (h *recordingHooks) BeforeRollback(_ context.Context, info satomic.HookInfo) {
+	h.record("BeforeRollback", info)
+}
+
+func (h *recordingHooks) AfterRollback(_ context.Context, info satomic.HookInfo) {
+	h.record("AfterRollback", info)
+}
+
+func (h *recordingHooks) OnRelease(_ context.Context, info satomic.HookInfo) {
+	h.record("OnRelease", info)
+}
+
+func TestQuerierHooks(t *testing.T) {
+	dbErr := errors.New("db")
+	cbErr := errors.New("cb")
+	hookErr := errors.New("hook")
+
+	testCases := []struct {
+		name          string
+		mocker        func(sqlmock.Sqlmock) sqlmock.Sqlmock
+		opts          []satomic.Option
+		commitErr     error
+		innerErr      error
+		outerErr      error
+		expectedErr   error
+		expectedCalls []string
+	}{
+		{name: "commit", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectExec("RELEASE 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectCommit()
+			return m
+		}, expectedCalls: []string{
+			"BeforeBegin 1 outer BEGIN",
+			"AfterBegin 1 outer BEGIN",
+			"BeforeSavepoint 2 outer/inner SAVEPOINT 1;",
+			"AfterSavepoint 2 outer/inner SAVEPOINT 1;",
+			"OnRelease 2 outer/inner RELEASE 1;",
+			"BeforeCommit 1 outer COMMIT",
+			"AfterCommit 1 outer COMMIT",
+		}},
+		{name: "rollback", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectExec("ROLLBACK TO 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectRollback()
+			return m
+		}, innerErr: cbErr, outerErr: cbErr, expectedErr: cbErr, expectedCalls: []string{
+			"BeforeBegin 1 outer BEGIN",
+			"AfterBegin 1 outer BEGIN",
+			"BeforeSavepoint 2 outer/inner SAVEPOINT 1;",
+			"AfterSavepoint 2 outer/inner SAVEPOINT 1;",
+			"BeforeRollback 2 outer/inner ROLLBACK TO 1; cause=cb",
+			"AfterRollback 2 outer/inner ROLLBACK TO 1; cause=cb",
+			"BeforeRollback 1 outer ROLLBACK cause=cb",
+			"AfterRollback 1 outer ROLLBACK cause=cb",
+		}},
+		{name: "rollback error", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectExec("RELEASE 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectRollback().WillReturnError(dbErr)
+			return m
+		}, outerErr: cbErr, expectedErr: dbErr, expectedCalls: []string{
+			"BeforeBegin 1 outer BEGIN",
+			"AfterBegin 1 outer BEGIN",
+			"BeforeSavepoint 2 outer/inner SAVEPOINT 1;",
+			"AfterSavepoint 2 outer/inner SAVEPOINT 1;",
+			"OnRelease 2 outer/inner RELEASE 1;",
+			"BeforeRollback 1 outer ROLLBACK cause=cb",
+			"AfterRollback 1 outer ROLLBACK err=db cause=cb",
+		}},
+		{name: "BeforeCommit error", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectExec("RELEASE 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectRollback()
+			return m
+		}, commitErr: hookErr, expectedErr: hookErr, expectedCalls: []string{
+			"BeforeBegin 1 outer BEGIN",
+			"AfterBegin 1 outer BEGIN",
+			"BeforeSavepoint 2 outer/inner SAVEPOINT 1;",
+			"AfterSavepoint 2 outer/inner SAVEPOINT 1;",
+			"OnRelease 2 outer/inner RELEASE 1;",
+			"BeforeCommit 1 outer COMMIT",
+			"BeforeRollback 1 outer ROLLBACK cause=hook",
+			"AfterRollback 1 outer ROLLBACK cause=hook",
+		}},
+		{name: "commit error", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectExec("RELEASE 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectCommit().WillReturnError(dbErr)
+			return m
+		}, expectedErr: dbErr, expectedCalls: []string{
+			"BeforeBegin 1 outer BEGIN",
+			"AfterBegin 1 outer BEGIN",
+			"BeforeSavepoint 2 outer/inner SAVEPOINT 1;",
+			"AfterSavepoint 2 outer/inner SAVEPOINT 1;",
+			"OnRelease 2 outer/inner RELEASE 1;",
+			"BeforeCommit 1 outer COMMIT",
+			"AfterCommit 1 outer COMMIT err=db",
+		}},
+		{name: "begin error", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin().WillReturnError(dbErr)
+			return m
+		}, expectedErr: dbErr, expectedCalls: []string{
+			"BeforeBegin 1 outer BEGIN",
+			"AfterBegin 1 outer BEGIN err=db",
+			"BeforeRollback 1 outer  cause=db",
+			"AfterRollback 1 outer  cause=db",
+		}},
+		{name: "lazy", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			return m
+		}, opts: []satomic.Option{satomic.WithLazyBegin()}, expectedCalls: []string{
+			"BeforeBegin 1 outer BEGIN",
+			"BeforeSavepoint 2 outer/inner SAVEPOINT 1;",
+			"OnRelease 2 outer/inner ",
+			"BeforeCommit 1 outer ",
+			"AfterCommit 1 outer ",
+		}},
+	}
+
+	ctx := context.Background()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, _sqlmock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal("Error creating sqlmock:", err)
+			}
+			defer db.Close() // nolint:errcheck
+
+			_sqlmock = tc.mocker(_sqlmock)
+
+			var calls []string
+			hooks := &recordingHooks{calls: &calls, commitErr: tc.commitErr}
+			q, err := satomic.NewQuerier(ctx, db, mock.NewSavepointer(io.Discard, true), sql.TxOptions{},
+				append(tc.opts, satomic.WithHooks(hooks))...)
+			if err != nil {
+				t.Fatal("Error creating Querier:", err)
+			}
+
+			atomicErr := q.AtomicWithOptions(satomic.AtomicOptions{Label: "outer"},
+				func(ctx context.Context, q satomic.Querier) error {
+					if ctx.Value(ctxKey("BeforeBegin")) == nil {
+						t.Error("Didn't get the context returned by BeforeBegin()")
+					}
+					if err := q.AtomicWithOptions(satomic.AtomicOptions{Label: "inner"},
+						func(ctx context.Context, q satomic.Querier) error {
+							if ctx.Value(ctxKey("BeforeSavepoint")) == nil {
+								t.Error("Didn't get the context returned by BeforeSavepoint()")
+							}
+							return tc.innerErr
+						}); err != nil && !errors.Is(err, tc.innerErr) {
+						t.Error("Unexpected inner error:", err)
+					}
+					return tc.outerErr
+				})
+			if tc.expectedErr == nil {
+				if atomicErr != nil {
+					t.Error("Unexpected error:", atomicErr)
+				}
+			} else if !errors.Is(atomicErr, tc.expectedErr) {
+				t.Errorf("Didn't get the expected error: %+v doesn't wrap %v", atomicErr, tc.expectedErr)
+			}
+			if !reflect.DeepEqual(calls, tc.expectedCalls) {
+				t.Errorf("Didn't get the expected hook calls:\n%q\n!=\n%q", calls, tc.expectedCalls)
+			}
+
+			if err := _sqlmock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestComposeHooks(t *testing.T) {
+	hookErr := errors.New("hook")
+
+	var calls []string
+	a := &recordingHooks{name: "a.", calls: &calls}
+	b := &recordingHooks{name: "b.", calls: &calls, commitErr: hookErr}
+	c := &recordingHooks{name: "c.", calls: &calls}
+	hooks := satomic.ComposeHooks(a, nil, satomic.ComposeHooks(b, c))
+
+	ctx := hooks.BeforeBegin(context.Background(), satomic.HookInfo{Depth: 1})
+	for _, name := range []string{"a.", "b.", "c."} {
+		if ctx.Value(ctxKey(name+"BeforeBegin")) == nil {
+			t.Errorf("Didn't get the context returned by %sBeforeBegin()", name)
+		}
+	}
+	hooks.AfterBegin(ctx, satomic.HookInfo{Depth: 1})
+	if err := hooks.BeforeCommit(ctx, satomic.HookInfo{Depth: 1}); err != hookErr {
+		t.Errorf("Didn't get the expected error: %v != %v", err, hookErr)
+	}
+
+	expectedCalls := []string{
+		"a.BeforeBegin 1  ",
+		"b.BeforeBegin 1  ",
+		"c.BeforeBegin 1  ",
+		"c.AfterBegin 1  ",
+		"b.AfterBegin 1  ",
+		"a.AfterBegin 1  ",
+		"a.BeforeCommit 1  ",
+		"b.BeforeCommit 1  ",
+	}
+	if !reflect.DeepEqual(calls, expectedCalls) {
+		t.Errorf("Didn't get the expected hook calls:\n%q\n!=\n%q", calls, expectedCalls)
+	}
+
+	if hooks := satomic.ComposeHooks(nil, a); hooks != a {
+		t.Error("Didn't get the only Hooks")
+	}
+}
+
+func TestNoopHooks(t *testing.T) {
+	ctx := context.Background()
+	var hooks satomic.Hooks = satomic.NoopHooks{}
+	if hooks.BeforeBegin(ctx, satomic.HookInfo{}) != ctx || hooks.BeforeSavepoint(ctx, satomic.HookInfo{}) != ctx {
+		t.Error("Didn't get the given context")
+	}
+	if err := hooks.BeforeCommit(ctx, satomic.HookInfo{}); err != nil {
+		t.Error("Unexpected error:", err)
+	}
+}
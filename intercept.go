@@ -0,0 +1,63 @@
+package satomic
+
+import (
+	"context"
+)
+
+// StatementInfo describes a statement run by a Querier
+type StatementInfo struct {
+	// Method is the name of the Querier method running the statement. e.g. "ExecContext" or "GetContext"
+	Method string
+	Query  string
+	Args   []interface{}
+	// InTransaction is set if the statement is run in a transaction
+	InTransaction bool
+	// Depth is how deeply the Querier is nested. See Querier.Depth()
+	Depth int
+	// SavepointName is the name of the Querier's savepoint or "" if the Querier isn't in a savepoint
+	SavepointName string
+	// Label identifies the Querier's transaction or savepoint. See AtomicOptions.Label
+	Label string
+}
+
+// StatementRunner runs a statement with the given query and arguments
+type StatementRunner func(ctx context.Context, query string, args ...interface{}) error
+
+// Interceptor wraps the running of a statement by a Querier. e.g. for logging or timing statements
+//
+// An Interceptor may change the context, query or arguments passed to next. e.g. to redact arguments or add SQL
+// comments. Returning an error without calling next prevents the statement from being run. e.g. to block writes
+// in read-only transactions.
+type Interceptor func(ctx context.Context, info StatementInfo, next StatementRunner) error
+
+// intercept runs the statement through the interceptors, starting with the first one
+func intercept(ctx context.Context, interceptors []Interceptor, info StatementInfo, run StatementRunner) error {
+	if len(interceptors) == 0 {
+		return run(ctx, info.Query, info.Args...)
+	}
+	return interceptors[0](ctx, info, func(ctx context.Context, query string, args ...interface{}) error {
+		info.Query, info.Args = query, args
+		return intercept(ctx, interceptors[1:], info, run)
+	})
+}
+
+// intercept runs the statement through q's interceptors
+func (q *querier) intercept(ctx context.Context, method, query string, args []interface{},
+	run StatementRunner) error {
+	if len(q.interceptors) == 0 {
+		return run(ctx, query, args...)
+	}
+	info := StatementInfo{Method: method, Query: query, Args: args, InTransaction: q.InTransaction(),
+		Depth: q.Depth(), SavepointName: q.savepointName, Label: q.label}
+	return intercept(ctx, q.interceptors, info, run)
+}
+
+// Intercept runs the statement with the Querier's Interceptors. It's intended for packages that extend a Querier,
+// like satomicx, and should be called once the statement is ready to be run. See AcquireTx()
+func Intercept(ctx context.Context, q Querier, method, query string, args []interface{},
+	run StatementRunner) error {
+	if _q, ok := q.(*querier); ok && _q != nil {
+		return _q.intercept(ctx, method, query, args, run)
+	}
+	return run(ctx, query, args...)
+}
@@ -0,0 +1,136 @@
+package satomic_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+import (
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+)
+
+import (
+	"github.com/dhui/satomic"
+	"github.com/dhui/satomic/savepointers/mock"
+)
+
+func TestQuerierInterceptors(t *testing.T) {
+	db, _sqlmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal("Error creating sqlmock:", err)
+	}
+	defer db.Close() // nolint:errcheck
+
+	_sqlmock.ExpectExec(`UPDATE 1; /\* outer \*/ /\* inner \*/`).WillReturnResult(sqlmock.NewResult(0, 1))
+	_sqlmock.ExpectBegin()
+	_sqlmock.ExpectQuery(`SELECT 1; /\* outer \*/ /\* inner \*/`).WillReturnRows(
+		sqlmock.NewRows([]string{"id"}).AddRow(1))
+	_sqlmock.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectQuery(`SELECT 2; /\* outer \*/ /\* inner \*/`).WillReturnRows(
+		sqlmock.NewRows([]string{"id"}).AddRow(2))
+	_sqlmock.ExpectExec("RELEASE 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectCommit()
+
+	var calls []string
+	comment := func(name string) satomic.Interceptor {
+		return func(ctx context.Context, info satomic.StatementInfo, next satomic.StatementRunner) error {
+			calls = append(calls, fmt.Sprintf("%s %s %q %v %v %d %s", name, info.Method, info.Query, info.Args,
+				info.InTransaction, info.Depth, info.Label))
+			return next(ctx, info.Query+" /* "+name+" */", info.Args...)
+		}
+	}
+
+	ctx := context.Background()
+	q, err := satomic.NewQuerier(ctx, db, mock.NewSavepointer(io.Discard, true), sql.TxOptions{},
+		satomic.WithInterceptors(comment("outer"), nil), satomic.WithInterceptors(comment("inner")))
+	if err != nil {
+		t.Fatal("Error creating Querier:", err)
+	}
+
+	if _, err := q.ExecContext(ctx, "UPDATE 1;", 1); err != nil {
+		t.Error(err)
+	}
+	if err := q.AtomicWithOptions(satomic.AtomicOptions{Label: "label"},
+		func(ctx context.Context, q satomic.Querier) error {
+			rows, err := q.QueryContext(ctx, "SELECT 1;")
+			if err != nil {
+				return err
+			}
+			rows.Close() // nolint:errcheck
+			if err := q.Atomic(func(ctx context.Context, q satomic.Querier) error {
+				var id int
+				return q.QueryRowContext(ctx, "SELECT 2;").Scan(&id)
+			}); err != nil {
+				return err
+			}
+			return nil
+		}); err != nil {
+		t.Error(err)
+	}
+
+	expectedCalls := []string{
+		`outer ExecContext "UPDATE 1;" [1] false 0 `,
+		`inner ExecContext "UPDATE 1; /* outer */" [1] false 0 `,
+		`outer QueryContext "SELECT 1;" [] true 1 label`,
+		`inner QueryContext "SELECT 1; /* outer */" [] true 1 label`,
+		`outer QueryRowContext "SELECT 2;" [] true 2 label`,
+		`inner QueryRowContext "SELECT 2; /* outer */" [] true 2 label`,
+	}
+	if !reflect.DeepEqual(calls, expectedCalls) {
+		t.Errorf("Didn't get the expected interceptor calls:\n%q\n!=\n%q", calls, expectedCalls)
+	}
+
+	if err := _sqlmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQuerierInterceptorsBlock(t *testing.T) {
+	errBlocked := errors.New("blocked")
+	dbErr := errors.New("db error")
+	blockWrites := func(ctx context.Context, info satomic.StatementInfo, next satomic.StatementRunner) error {
+		if !strings.HasPrefix(info.Query, "SELECT") {
+			return errBlocked
+		}
+		return next(ctx, info.Query, info.Args...)
+	}
+
+	db, _sqlmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal("Error creating sqlmock:", err)
+	}
+	defer db.Close() // nolint:errcheck
+
+	_sqlmock.ExpectQuery("SELECT 1;").WillReturnError(dbErr)
+
+	ctx := context.Background()
+	q, err := satomic.NewQuerier(ctx, db, mock.NewSavepointer(io.Discard, true), sql.TxOptions{},
+		satomic.WithInterceptors(blockWrites))
+	if err != nil {
+		t.Fatal("Error creating Querier:", err)
+	}
+
+	if _, err := q.ExecContext(ctx, "UPDATE 1;"); err != errBlocked {
+		t.Errorf("Didn't get the expected error: %v != %v", err, errBlocked)
+	}
+	if _, err := q.QueryContext(ctx, "DELETE 1;"); err != errBlocked {
+		t.Errorf("Didn't get the expected error: %v != %v", err, errBlocked)
+	}
+	if err := q.QueryRowContext(ctx, "INSERT 1;").Scan(new(int)); err != errBlocked {
+		t.Errorf("Didn't get the expected error: %v != %v", err, errBlocked)
+	}
+	// The statement's error is returned by the row
+	if err := q.QueryRowContext(ctx, "SELECT 1;").Scan(new(int)); err != dbErr {
+		t.Errorf("Didn't get the expected error: %v != %v", err, dbErr)
+	}
+
+	if err := _sqlmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
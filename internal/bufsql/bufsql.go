@@ -0,0 +1,183 @@
+// Package bufsql buffers the rows of a query so that they can be read after the connection is used to run other
+// statements. database/sql doesn't provide a way to create a *sql.Rows or *sql.Row, so the buffered rows are
+// returned by querying an in-memory driver with the Result. e.g. DB().QueryContext(ctx, "", result)
+package bufsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+)
+
+// errUnsupported is returned for any use of the driver other than querying a Result
+var errUnsupported = errors.New("bufsql: only querying a Result is supported")
+
+var db = sql.OpenDB(connector{})
+
+// DB returns the *sql.DB that returns the rows of the Result given as its only argument. The query is ignored.
+func DB() *sql.DB {
+	return db
+}
+
+// Rows returns a *sql.Rows that reads the rows of the Result
+func Rows(ctx context.Context, result *Result) (*sql.Rows, error) {
+	return db.QueryContext(ctx, "", result)
+}
+
+// Row returns a *sql.Row that reads the first row of the Result
+func Row(ctx context.Context, result *Result) *sql.Row {
+	return db.QueryRowContext(ctx, "", result)
+}
+
+// resultSet is a buffered result set
+type resultSet struct {
+	columns []string
+	types   []*sql.ColumnType
+	rows    [][]driver.Value
+	// err is the error that ended the result set instead of io.EOF
+	err error
+}
+
+// Result is the buffered result sets of a query
+type Result struct {
+	sets []*resultSet
+}
+
+// Read reads and closes the rows. An error reading the rows is returned once the buffered rows before it are read.
+func Read(rows *sql.Rows) *Result {
+	defer rows.Close() // nolint:errcheck
+	result := &Result{}
+	for {
+		set := &resultSet{}
+		result.sets = append(result.sets, set)
+		if set.err = set.read(rows); set.err != nil {
+			return result
+		}
+		if !rows.NextResultSet() {
+			break
+		}
+	}
+	if err := rows.Err(); err != nil {
+		result.sets[len(result.sets)-1].err = err
+	} else if err := rows.Close(); err != nil {
+		result.sets[len(result.sets)-1].err = err
+	}
+	return result
+}
+
+// read reads the rows of the current result set
+func (s *resultSet) read(rows *sql.Rows) error {
+	var err error
+	if s.columns, err = rows.Columns(); err != nil {
+		return err
+	}
+	if s.types, err = rows.ColumnTypes(); err != nil {
+		s.columns = nil
+		return err
+	}
+	for rows.Next() {
+		// Scanning into an interface{} copies the driver's value. e.g. []byte
+		values := make([]interface{}, len(s.columns))
+		dest := make([]interface{}, len(values))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		row := make([]driver.Value, len(values))
+		for i, v := range values {
+			row[i] = v
+		}
+		s.rows = append(s.rows, row)
+	}
+	return rows.Err()
+}
+
+type connector struct{}
+
+func (c connector) Connect(context.Context) (driver.Conn, error) { return conn{}, nil }
+
+func (c connector) Driver() driver.Driver { return drv{} }
+
+type drv struct{}
+
+func (d drv) Open(string) (driver.Conn, error) { return conn{}, nil }
+
+type conn struct{}
+
+func (c conn) Prepare(string) (driver.Stmt, error) { return nil, errUnsupported }
+
+func (c conn) Close() error { return nil }
+
+func (c conn) Begin() (driver.Tx, error) { return nil, errUnsupported }
+
+// CheckNamedValue accepts the Result as-is
+func (c conn) CheckNamedValue(v *driver.NamedValue) error {
+	if _, ok := v.Value.(*Result); !ok {
+		return errUnsupported
+	}
+	return nil
+}
+
+func (c conn) QueryContext(_ context.Context, _ string, args []driver.NamedValue) (driver.Rows, error) {
+	if len(args) != 1 {
+		return nil, errUnsupported
+	}
+	result, ok := args[0].Value.(*Result)
+	if !ok || result == nil || len(result.sets) == 0 {
+		return nil, errUnsupported
+	}
+	return &bufRows{sets: result.sets}, nil
+}
+
+// bufRows implements driver.Rows for the buffered result sets
+type bufRows struct {
+	sets []*resultSet
+	// row is the index of the next row of the current result set, sets[0]
+	row int
+}
+
+func (r *bufRows) Columns() []string { return r.sets[0].columns }
+
+func (r *bufRows) Close() error { return nil }
+
+func (r *bufRows) Next(dest []driver.Value) error {
+	set := r.sets[0]
+	if r.row >= len(set.rows) {
+		if set.err != nil {
+			return set.err
+		}
+		return io.EOF
+	}
+	copy(dest, set.rows[r.row])
+	r.row++
+	return nil
+}
+
+func (r *bufRows) HasNextResultSet() bool { return len(r.sets) > 1 }
+
+func (r *bufRows) NextResultSet() error {
+	if !r.HasNextResultSet() {
+		return io.EOF
+	}
+	r.sets, r.row = r.sets[1:], 0
+	return nil
+}
+
+func (r *bufRows) ColumnTypeDatabaseTypeName(i int) string {
+	return r.sets[0].types[i].DatabaseTypeName()
+}
+
+func (r *bufRows) ColumnTypeLength(i int) (int64, bool) { return r.sets[0].types[i].Length() }
+
+func (r *bufRows) ColumnTypeNullable(i int) (bool, bool) { return r.sets[0].types[i].Nullable() }
+
+func (r *bufRows) ColumnTypePrecisionScale(i int) (int64, int64, bool) {
+	return r.sets[0].types[i].DecimalSize()
+}
+
+func (r *bufRows) ColumnTypeScanType(i int) reflect.Type { return r.sets[0].types[i].ScanType() }
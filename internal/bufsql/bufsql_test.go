@@ -0,0 +1,113 @@
+package bufsql_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+import (
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+)
+
+import (
+	"github.com/dhui/satomic/internal/bufsql"
+)
+
+func TestRead(t *testing.T) {
+	rowErr := errors.New("row error")
+
+	newRows := func() *sqlmock.Rows { return sqlmock.NewRows([]string{"id", "name"}) }
+
+	testCases := []struct {
+		name          string
+		rows          func() []*sqlmock.Rows
+		expectedRows  [][][]string
+		expectedErr   error
+		expectedNoRow bool
+	}{
+		{name: "no rows", rows: func() []*sqlmock.Rows { return []*sqlmock.Rows{newRows()} },
+			expectedRows: [][][]string{nil}, expectedErr: nil, expectedNoRow: true},
+		{name: "rows", rows: func() []*sqlmock.Rows {
+			return []*sqlmock.Rows{newRows().AddRow(1, "a").AddRow(2, []byte("b"))}
+		}, expectedRows: [][][]string{{{"1", "a"}, {"2", "b"}}}, expectedErr: nil},
+		{name: "row error", rows: func() []*sqlmock.Rows {
+			return []*sqlmock.Rows{newRows().AddRow(1, "a").AddRow(2, "b").RowError(1, rowErr)}
+		}, expectedRows: [][][]string{{{"1", "a"}}}, expectedErr: rowErr},
+		{name: "result sets", rows: func() []*sqlmock.Rows {
+			return []*sqlmock.Rows{newRows().AddRow(1, "a"), newRows().AddRow(2, "b")}
+		}, expectedRows: [][][]string{{{"1", "a"}}, {{"2", "b"}}}, expectedErr: nil},
+	}
+
+	ctx := context.Background()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, _sqlmock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal("Error creating sqlmock:", err)
+			}
+			defer db.Close() // nolint:errcheck
+
+			_sqlmock.ExpectQuery("SELECT 1;").WillReturnRows(tc.rows()...)
+			_sqlmock.ExpectQuery("SELECT 1;").WillReturnRows(tc.rows()...)
+
+			read := func() *bufsql.Result {
+				t.Helper()
+				rows, err := db.QueryContext(ctx, "SELECT 1;")
+				if err != nil {
+					t.Fatal(err)
+				}
+				return bufsql.Read(rows)
+			}
+
+			rows, err := bufsql.Rows(ctx, read())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rows.Close() // nolint:errcheck
+			var sets [][][]string
+			for {
+				if columns, err := rows.Columns(); err != nil || !reflect.DeepEqual(columns, []string{"id", "name"}) {
+					t.Errorf("Didn't get the expected columns: %v %v", columns, err)
+				}
+				var set [][]string
+				for rows.Next() {
+					var id, name string
+					if err := rows.Scan(&id, &name); err != nil {
+						t.Error(err)
+					}
+					set = append(set, []string{id, name})
+				}
+				sets = append(sets, set)
+				if !rows.NextResultSet() {
+					break
+				}
+			}
+			if err := rows.Err(); err != tc.expectedErr {
+				t.Errorf("Didn't get the expected error: %v != %v", err, tc.expectedErr)
+			}
+			if !reflect.DeepEqual(sets, tc.expectedRows) {
+				t.Errorf("Didn't get the expected rows: %q != %q", sets, tc.expectedRows)
+			}
+
+			var id, name string
+			err = bufsql.Row(ctx, read()).Scan(&id, &name)
+			switch {
+			case tc.expectedNoRow:
+				if err != sql.ErrNoRows {
+					t.Errorf("Didn't get the expected error: %v != %v", err, sql.ErrNoRows)
+				}
+			case err != nil:
+				t.Error(err)
+			case id != tc.expectedRows[0][0][0] || name != tc.expectedRows[0][0][1]:
+				t.Errorf("Didn't get the expected row: %q, %q", id, name)
+			}
+
+			if err := _sqlmock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+// Package errsql creates database/sql values that return a given error. database/sql doesn't provide a way to create
+// a *sql.Row or *sql.Stmt with an error, so returning nil is the only other option and using nil panics.
+package errsql
+
+import (
+	"context"
+	"database/sql"
+)
+
+// done is a closed channel for doneContext
+var done = func() chan struct{} {
+	c := make(chan struct{})
+	close(c)
+	return c
+}()
+
+// doneContext is a context that's already done with the error. A *sql.Tx returns the error of a done context
+// without using the transaction, so the zero *sql.Tx can be used to create values with the error.
+type doneContext struct {
+	context.Context
+	err error
+}
+
+func (c doneContext) Done() <-chan struct{} { return done }
+
+func (c doneContext) Err() error { return c.err }
+
+// Context returns a context that's already done with the error. Any *sql.Tx method called with the context returns
+// the error without using the transaction. e.g. for packages that wrap *sql.Tx like sqlx
+func Context(err error) context.Context {
+	return doneContext{Context: context.Background(), err: err}
+}
+
+// Row returns a *sql.Row whose Err() and Scan() return the error. The error must not be nil.
+func Row(err error) *sql.Row {
+	return (&sql.Tx{}).QueryRowContext(Context(err), "")
+}
+
+// Stmt returns a *sql.Stmt whose methods return the error. The error must not be nil.
+func Stmt(err error) *sql.Stmt {
+	return (&sql.Tx{}).StmtContext(Context(err), nil)
+}
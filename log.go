@@ -0,0 +1,158 @@
+package satomic
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// LogOptions configures the logging done by WithLogger()
+type LogOptions struct {
+	// SlowTransaction is how long a transaction or savepoint can run before it's logged at the warn level when it
+	// ends. Zero disables slow transaction warnings.
+	SlowTransaction time.Duration
+	// Statements logs the statements run by the Querier. See WithInterceptors()
+	Statements bool
+	// SlowStatement is how long a statement can run before it's logged at the warn level. Zero disables slow
+	// statement warnings.
+	SlowStatement time.Duration
+	// RedactArgs returns the statement's arguments to log. e.g. with sensitive values replaced.
+	// If nil, the arguments aren't logged.
+	RedactArgs func(query string, args []interface{}) []interface{}
+}
+
+// logger logs the transaction and savepoint lifecycle and statements for WithLogger()
+type logger struct {
+	NoopHooks
+	logger *slog.Logger
+	opts   LogOptions
+}
+
+// satomicPkg is the import path of this package
+const satomicPkg = "github.com/dhui/satomic"
+
+// isSatomicFunc determines whether or not the named function is in satomic or one of its packages. e.g. satomicx
+func isSatomicFunc(name string) bool {
+	// Function names are qualified by their package's import path. e.g. github.com/dhui/satomic.(*querier).Atomic
+	pkg := name
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		if j := strings.Index(pkg[i:], "."); j >= 0 {
+			pkg = pkg[:i+j]
+		}
+	}
+	if strings.HasSuffix(pkg, "_test") {
+		return false
+	}
+	return pkg == satomicPkg || strings.HasPrefix(pkg, satomicPkg+"/") || strings.HasPrefix(name, "runtime.")
+}
+
+// callerPC returns the program counter of the first caller outside of satomic. i.e. the caller of Atomic() or of
+// the statement
+func callerPC() uintptr {
+	var pcs [64]uintptr
+	n := runtime.Callers(2, pcs[:])
+	for _, pc := range pcs[:n] {
+		frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+		if !isSatomicFunc(frame.Function) {
+			return pc
+		}
+	}
+	return 0
+}
+
+// log emits a record with the originating caller as its source
+func (l *logger) log(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	if !l.logger.Enabled(ctx, level) {
+		return
+	}
+	r := slog.NewRecord(time.Now(), level, msg, callerPC())
+	r.AddAttrs(attrs...)
+	l.logger.Handler().Handle(ctx, r) // nolint:errcheck
+}
+
+// logOp logs the statement run for a transaction or savepoint operation. Nothing is logged if no statement was run.
+func (l *logger) logOp(ctx context.Context, msg string, info HookInfo, ended bool) {
+	if info.Statement == "" {
+		return
+	}
+	level := slog.LevelDebug
+	attrs := []slog.Attr{slog.String("statement", info.Statement), slog.Duration("duration", info.Duration),
+		slog.Int("depth", info.Depth)}
+	if info.SavepointName != "" {
+		attrs = append(attrs, slog.String("savepoint", info.SavepointName))
+	}
+	if info.Label != "" {
+		attrs = append(attrs, slog.String("label", info.Label))
+	}
+	if ended {
+		attrs = append(attrs, slog.Duration("elapsed", info.Elapsed))
+		if l.opts.SlowTransaction > 0 && info.Elapsed >= l.opts.SlowTransaction {
+			level = slog.LevelWarn
+			attrs = append(attrs, slog.Bool("slow", true))
+		}
+	}
+	if info.Cause != nil {
+		attrs = append(attrs, slog.Any("cause", info.Cause))
+	}
+	if info.Err != nil {
+		level = slog.LevelWarn
+		attrs = append(attrs, slog.Any("error", info.Err))
+	}
+	l.log(ctx, level, msg, attrs...)
+}
+
+func (l *logger) AfterBegin(ctx context.Context, info HookInfo) {
+	l.logOp(ctx, "Begin transaction", info, false)
+}
+
+func (l *logger) AfterSavepoint(ctx context.Context, info HookInfo) {
+	l.logOp(ctx, "Create savepoint", info, false)
+}
+
+func (l *logger) AfterCommit(ctx context.Context, info HookInfo) {
+	l.logOp(ctx, "Commit transaction", info, true)
+}
+
+func (l *logger) AfterRollback(ctx context.Context, info HookInfo) {
+	if info.SavepointName != "" {
+		l.logOp(ctx, "Rollback savepoint", info, true)
+		return
+	}
+	l.logOp(ctx, "Rollback transaction", info, true)
+}
+
+func (l *logger) OnRelease(ctx context.Context, info HookInfo) {
+	l.logOp(ctx, "Release savepoint", info, true)
+}
+
+// intercept logs the statement once it's run
+func (l *logger) intercept(ctx context.Context, info StatementInfo, next StatementRunner) error {
+	start := time.Now()
+	err := next(ctx, info.Query, info.Args...)
+	duration := time.Since(start)
+
+	level := slog.LevelDebug
+	attrs := []slog.Attr{slog.String("method", info.Method), slog.String("query", info.Query)}
+	if l.opts.RedactArgs != nil {
+		attrs = append(attrs, slog.Any("args", l.opts.RedactArgs(info.Query, info.Args)))
+	}
+	attrs = append(attrs, slog.Duration("duration", duration), slog.Bool("in_transaction", info.InTransaction),
+		slog.Int("depth", info.Depth))
+	if info.SavepointName != "" {
+		attrs = append(attrs, slog.String("savepoint", info.SavepointName))
+	}
+	if info.Label != "" {
+		attrs = append(attrs, slog.String("label", info.Label))
+	}
+	if l.opts.SlowStatement > 0 && duration >= l.opts.SlowStatement {
+		level = slog.LevelWarn
+		attrs = append(attrs, slog.Bool("slow", true))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	l.log(ctx, level, "Run statement", attrs...)
+	return err
+}
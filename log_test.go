@@ -0,0 +1,231 @@
+package satomic_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+import (
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+)
+
+import (
+	"github.com/dhui/satomic"
+	"github.com/dhui/satomic/savepointers/mock"
+)
+
+// recordingHandler records the slog records that it handles
+type recordingHandler struct {
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+func (h *recordingHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *recordingHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, r.Clone())
+	return nil
+}
+
+func (h *recordingHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+
+func (h *recordingHandler) WithGroup(string) slog.Handler { return h }
+
+// loggedRecord is the part of a slog record that's compared by tests
+type loggedRecord struct {
+	level slog.Level
+	msg   string
+	attrs map[string]string
+}
+
+func (h *recordingHandler) logged(t *testing.T) []loggedRecord {
+	t.Helper()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	logged := make([]loggedRecord, 0, len(h.records))
+	for _, r := range h.records {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		if filepath.Base(frame.File) != "log_test.go" {
+			t.Errorf("Didn't get the originating caller for %q: %s:%d", r.Message, frame.File, frame.Line)
+		}
+		lr := loggedRecord{level: r.Level, msg: r.Message, attrs: map[string]string{}}
+		r.Attrs(func(a slog.Attr) bool {
+			switch a.Key {
+			case "duration", "elapsed":
+				// Not deterministic
+			default:
+				lr.attrs[a.Key] = a.Value.String()
+			}
+			return true
+		})
+		logged = append(logged, lr)
+	}
+	return logged
+}
+
+func TestQuerierLogger(t *testing.T) {
+	dbErr := errors.New("db error")
+	cbErr := errors.New("cb error")
+
+	db, _sqlmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal("Error creating sqlmock:", err)
+	}
+	defer db.Close() // nolint:errcheck
+
+	_sqlmock.ExpectBegin()
+	_sqlmock.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectExec("UPDATE 1;").WithArgs("secret").WillReturnResult(sqlmock.NewResult(0, 1))
+	_sqlmock.ExpectExec("RELEASE 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectExec("SAVEPOINT 2;").WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectExec("ROLLBACK TO 2;").WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectCommit()
+	_sqlmock.ExpectBegin()
+	_sqlmock.ExpectRollback().WillReturnError(dbErr)
+
+	handler := &recordingHandler{}
+	ctx := context.Background()
+	q, err := satomic.NewQuerier(ctx, db, mock.NewSavepointer(io.Discard, true), sql.TxOptions{},
+		satomic.WithLazyBegin(), satomic.WithLogger(slog.New(handler), satomic.LogOptions{
+			SlowTransaction: time.Hour,
+			Statements:      true,
+			RedactArgs: func(_ string, args []interface{}) []interface{} {
+				redacted := make([]interface{}, len(args))
+				for i := range redacted {
+					redacted[i] = "REDACTED"
+				}
+				return redacted
+			},
+		}))
+	if err != nil {
+		t.Fatal("Error creating Querier:", err)
+	}
+
+	// Nothing is logged without statements since nothing is run. See WithLazyBegin()
+	if err := q.Atomic(func(ctx context.Context, q satomic.Querier) error { return nil }); err != nil {
+		t.Error(err)
+	}
+	if err := q.AtomicWithOptions(satomic.AtomicOptions{Label: "outer"},
+		func(ctx context.Context, q satomic.Querier) error {
+			if err := q.Atomic(func(ctx context.Context, q satomic.Querier) error {
+				_, err := q.ExecContext(ctx, "UPDATE 1;", "secret")
+				return err
+			}); err != nil {
+				return err
+			}
+			if err := q.Atomic(func(ctx context.Context, q satomic.Querier) error {
+				if tx := q.Tx(); tx == nil {
+					t.Error("Expected a transaction")
+				}
+				return cbErr
+			}); !errors.Is(err, cbErr) {
+				t.Errorf("Didn't get the expected error: %+v doesn't wrap %v", err, cbErr)
+			}
+			return nil
+		}); err != nil {
+		t.Error(err)
+	}
+	if err := q.Atomic(func(ctx context.Context, q satomic.Querier) error {
+		q.Tx()
+		return cbErr
+	}); !errors.Is(err, dbErr) {
+		t.Errorf("Didn't get the expected error: %+v doesn't wrap %v", err, dbErr)
+	}
+
+	savepointAttrs := func(attrs map[string]string) map[string]string {
+		attrs["depth"] = "2"
+		attrs["label"] = "outer"
+		return attrs
+	}
+	logged := handler.logged(t)
+	for i := range logged {
+		// Savepoint names are random
+		if _, ok := logged[i].attrs["savepoint"]; ok {
+			logged[i].attrs["savepoint"] = "name"
+		}
+	}
+	expected := []loggedRecord{
+		{level: slog.LevelDebug, msg: "Begin transaction",
+			attrs: map[string]string{"statement": "BEGIN", "depth": "1", "label": "outer"}},
+		{level: slog.LevelDebug, msg: "Create savepoint",
+			attrs: savepointAttrs(map[string]string{"statement": "SAVEPOINT 1;", "savepoint": "name"})},
+		{level: slog.LevelDebug, msg: "Run statement",
+			attrs: savepointAttrs(map[string]string{"method": "ExecContext", "query": "UPDATE 1;",
+				"args": "[REDACTED]", "in_transaction": "true", "savepoint": "name"})},
+		{level: slog.LevelDebug, msg: "Release savepoint",
+			attrs: savepointAttrs(map[string]string{"statement": "RELEASE 1;", "savepoint": "name"})},
+		{level: slog.LevelDebug, msg: "Create savepoint",
+			attrs: savepointAttrs(map[string]string{"statement": "SAVEPOINT 2;", "savepoint": "name"})},
+		{level: slog.LevelDebug, msg: "Rollback savepoint",
+			attrs: savepointAttrs(map[string]string{"statement": "ROLLBACK TO 2;", "savepoint": "name",
+				"cause": "cb error"})},
+		{level: slog.LevelDebug, msg: "Commit transaction",
+			attrs: map[string]string{"statement": "COMMIT", "depth": "1", "label": "outer"}},
+		{level: slog.LevelDebug, msg: "Begin transaction", attrs: map[string]string{"statement": "BEGIN",
+			"depth": "1"}},
+		{level: slog.LevelWarn, msg: "Rollback transaction", attrs: map[string]string{"statement": "ROLLBACK",
+			"depth": "1", "cause": "cb error", "error": "db error"}},
+	}
+	if !reflect.DeepEqual(logged, expected) {
+		t.Errorf("Didn't get the expected records:\n%+v\n!=\n%+v", logged, expected)
+	}
+
+	if err := _sqlmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQuerierLoggerSlow(t *testing.T) {
+	db, _sqlmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal("Error creating sqlmock:", err)
+	}
+	defer db.Close() // nolint:errcheck
+
+	_sqlmock.ExpectBegin()
+	_sqlmock.ExpectExec("UPDATE 1;").WillDelayFor(10 * time.Millisecond).WillReturnResult(sqlmock.NewResult(0, 1))
+	_sqlmock.ExpectCommit()
+
+	handler := &recordingHandler{}
+	ctx := context.Background()
+	q, err := satomic.NewQuerier(ctx, db, mock.NewSavepointer(io.Discard, true), sql.TxOptions{},
+		satomic.WithLogger(slog.New(handler), satomic.LogOptions{SlowTransaction: time.Millisecond,
+			Statements: true, SlowStatement: time.Millisecond}))
+	if err != nil {
+		t.Fatal("Error creating Querier:", err)
+	}
+
+	if err := q.Atomic(func(ctx context.Context, q satomic.Querier) error {
+		_, err := q.ExecContext(ctx, "UPDATE 1;", 1)
+		return err
+	}); err != nil {
+		t.Error(err)
+	}
+
+	expected := []loggedRecord{
+		{level: slog.LevelDebug, msg: "Begin transaction", attrs: map[string]string{"statement": "BEGIN",
+			"depth": "1"}},
+		{level: slog.LevelWarn, msg: "Run statement", attrs: map[string]string{"method": "ExecContext",
+			"query": "UPDATE 1;", "in_transaction": "true", "depth": "1", "slow": "true"}},
+		{level: slog.LevelWarn, msg: "Commit transaction", attrs: map[string]string{"statement": "COMMIT",
+			"depth": "1", "slow": "true"}},
+	}
+	if logged := handler.logged(t); !reflect.DeepEqual(logged, expected) {
+		t.Errorf("Didn't get the expected records:\n%+v\n!=\n%+v", logged, expected)
+	}
+
+	if err := _sqlmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,115 @@
+package satomic
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// Outcome is how a transaction or savepoint ended
+type Outcome int
+
+const (
+	// OutcomeCommitted is used when the transaction is committed
+	OutcomeCommitted Outcome = iota
+	// OutcomeReleased is used when the savepoint is released
+	OutcomeReleased
+	// OutcomeRolledBack is used when the transaction or savepoint is rolled back, including when the transaction
+	// fails to commit
+	OutcomeRolledBack
+	// OutcomeUnknown is used when it's unknown whether or not the transaction was committed or the savepoint was
+	// released. See ErrCommitOutcomeUnknown
+	OutcomeUnknown
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeCommitted:
+		return "committed"
+	case OutcomeReleased:
+		return "released"
+	case OutcomeRolledBack:
+		return "rolled_back"
+	default:
+		return "unknown"
+	}
+}
+
+// Recorder records metrics for the transactions and savepoints created by Atomic(). Implementations must be safe for
+// concurrent use. e.g. github.com/dhui/satomic/satomicmetrics
+type Recorder interface {
+	// RecordStart is called once a transaction (depth 1) is begun or a savepoint (depth 2+) is created by Atomic().
+	// Nothing is recorded for transactions and savepoints that fail to start or aren't started. See WithLazyBegin()
+	RecordStart(label string, depth int)
+	// RecordEnd is called once the started transaction or savepoint ends with how long it ran
+	RecordEnd(label string, depth int, outcome Outcome, elapsed time.Duration)
+	// RecordRetry is called when AtomicWithRetry() retries the transaction
+	RecordRetry(label string)
+}
+
+// recorderHooks adapts a Recorder to Hooks
+type recorderHooks struct {
+	NoopHooks
+	recorder Recorder
+}
+
+// startedKey is the context key of the *atomic.Bool that's set once the transaction or savepoint is started
+type startedKey struct{}
+
+func (h recorderHooks) BeforeBegin(ctx context.Context, _ HookInfo) context.Context {
+	return context.WithValue(ctx, startedKey{}, new(atomic.Bool))
+}
+
+func (h recorderHooks) AfterBegin(ctx context.Context, info HookInfo) {
+	h.start(ctx, info)
+}
+
+func (h recorderHooks) BeforeSavepoint(ctx context.Context, _ HookInfo) context.Context {
+	return context.WithValue(ctx, startedKey{}, new(atomic.Bool))
+}
+
+func (h recorderHooks) AfterSavepoint(ctx context.Context, info HookInfo) {
+	h.start(ctx, info)
+}
+
+func (h recorderHooks) AfterCommit(ctx context.Context, info HookInfo) {
+	outcome := OutcomeCommitted
+	if errors.Is(info.Err, ErrCommitOutcomeUnknown) {
+		outcome = OutcomeUnknown
+	} else if info.Err != nil {
+		outcome = OutcomeRolledBack
+	}
+	h.end(ctx, info, outcome)
+}
+
+func (h recorderHooks) AfterRollback(ctx context.Context, info HookInfo) {
+	h.end(ctx, info, OutcomeRolledBack)
+}
+
+func (h recorderHooks) OnRelease(ctx context.Context, info HookInfo) {
+	outcome := OutcomeReleased
+	if info.Err != nil {
+		// The savepoint may or may not have been released
+		outcome = OutcomeUnknown
+	}
+	h.end(ctx, info, outcome)
+}
+
+// start records the start of the transaction or savepoint if it was begun or created
+func (h recorderHooks) start(ctx context.Context, info HookInfo) {
+	started, ok := ctx.Value(startedKey{}).(*atomic.Bool)
+	if !ok || info.Err != nil {
+		return
+	}
+	started.Store(true)
+	h.recorder.RecordStart(info.Label, info.Depth)
+}
+
+// end records the end of the transaction or savepoint if it was started. With WithLazyBegin(), transactions and
+// savepoints without any statements aren't started.
+func (h recorderHooks) end(ctx context.Context, info HookInfo, outcome Outcome) {
+	if started, ok := ctx.Value(startedKey{}).(*atomic.Bool); ok && started.Load() {
+		h.recorder.RecordEnd(info.Label, info.Depth, outcome, info.Elapsed)
+	}
+}
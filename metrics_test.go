@@ -0,0 +1,217 @@
+package satomic_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+import (
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+)
+
+import (
+	"github.com/dhui/satomic"
+	"github.com/dhui/satomic/savepointers/mock"
+)
+
+type recordingRecorder struct {
+	mu      sync.Mutex
+	records []string
+}
+
+func (r *recordingRecorder) record(s string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = append(r.records, s)
+}
+
+func (r *recordingRecorder) RecordStart(label string, depth int) {
+	r.record(fmt.Sprintf("start %s %d", label, depth))
+}
+
+func (r *recordingRecorder) RecordEnd(label string, depth int, outcome satomic.Outcome, elapsed time.Duration) {
+	if elapsed <= 0 {
+		r.record("non-positive elapsed")
+	}
+	r.record(fmt.Sprintf("end %s %d %v", label, depth, outcome))
+}
+
+func (r *recordingRecorder) RecordRetry(label string) { r.record("retry " + label) }
+
+func TestQuerierRecorder(t *testing.T) {
+	cbErr := errors.New("cb error")
+
+	db, _sqlmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal("Error creating sqlmock:", err)
+	}
+	defer db.Close() // nolint:errcheck
+
+	_sqlmock.ExpectBegin()
+	_sqlmock.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectExec("RELEASE 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectExec("SAVEPOINT 2;").WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectExec("ROLLBACK TO 2;").WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectCommit()
+	_sqlmock.ExpectBegin()
+	_sqlmock.ExpectCommit().WillReturnError(io.ErrUnexpectedEOF)
+	_sqlmock.ExpectBegin()
+	_sqlmock.ExpectRollback()
+	_sqlmock.ExpectBegin()
+	_sqlmock.ExpectCommit()
+
+	recorder := &recordingRecorder{}
+	ctx := context.Background()
+	q, err := satomic.NewQuerier(ctx, db, mock.NewSavepointer(io.Discard, true), sql.TxOptions{},
+		satomic.WithRecorder(recorder))
+	if err != nil {
+		t.Fatal("Error creating Querier:", err)
+	}
+
+	if err := q.AtomicWithOptions(satomic.AtomicOptions{Label: "outer"},
+		func(ctx context.Context, q satomic.Querier) error {
+			if err := q.AtomicWithOptions(satomic.AtomicOptions{Label: "released"},
+				func(context.Context, satomic.Querier) error { return nil }); err != nil {
+				return err
+			}
+			if err := q.Atomic(func(context.Context, satomic.Querier) error { return cbErr }); err == nil {
+				t.Error("Expected an error")
+			}
+			return nil
+		}); err != nil {
+		t.Error(err)
+	}
+	if err := q.Atomic(func(context.Context, satomic.Querier) error { return nil }); err == nil {
+		t.Error("Expected an error")
+	}
+	attempts := 0
+	policy := satomic.RetryPolicy{MaxAttempts: 2, Retryable: func(error) bool { return true }, Label: "retried"}
+	if err := q.AtomicWithRetry(policy, func(context.Context, satomic.Querier) error {
+		attempts++
+		if attempts == 1 {
+			return cbErr
+		}
+		return nil
+	}); err != nil {
+		t.Error(err)
+	}
+
+	expected := []string{
+		"start outer 1",
+		"start outer/released 2",
+		"end outer/released 2 released",
+		"start outer 2",
+		"end outer 2 rolled_back",
+		"end outer 1 committed",
+		"start  1",
+		"end  1 unknown",
+		"start retried 1",
+		"end retried 1 rolled_back",
+		"retry retried",
+		"start retried 1",
+		"end retried 1 committed",
+	}
+	if !reflect.DeepEqual(recorder.records, expected) {
+		t.Errorf("Didn't get the expected records:\n%q\n!=\n%q", recorder.records, expected)
+	}
+
+	if err := _sqlmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQuerierRecorderLazyBegin(t *testing.T) {
+	beginErr := errors.New("begin error")
+
+	db, _sqlmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal("Error creating sqlmock:", err)
+	}
+	defer db.Close() // nolint:errcheck
+
+	_sqlmock.ExpectBegin().WillReturnError(beginErr)
+	_sqlmock.ExpectBegin()
+	_sqlmock.ExpectExec("SAVEPOINT 3;").WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectExec("UPDATE 1;").WillReturnResult(sqlmock.NewResult(0, 1))
+	_sqlmock.ExpectExec("RELEASE 3;").WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectCommit()
+
+	recorder := &recordingRecorder{}
+	ctx := context.Background()
+	q, err := satomic.NewQuerier(ctx, db, mock.NewSavepointer(io.Discard, true), sql.TxOptions{},
+		satomic.WithLazyBegin(), satomic.WithRecorder(recorder))
+	if err != nil {
+		t.Fatal("Error creating Querier:", err)
+	}
+
+	noop := func(ctx context.Context, q satomic.Querier) error {
+		if err := q.AtomicNamed("noop", func(context.Context, satomic.Querier) error { return nil }); err != nil {
+			return err
+		}
+		return nil
+	}
+	exec := func(ctx context.Context, q satomic.Querier) error {
+		_, err := q.ExecContext(ctx, "UPDATE 1;")
+		return err
+	}
+
+	// Transactions and savepoints without statements aren't started
+	if err := q.AtomicNamed("noop", noop); err != nil {
+		t.Error(err)
+	}
+	if err := q.AtomicNamed("begin error", exec); !errors.Is(err, beginErr) {
+		t.Errorf("Didn't get the expected error: %+v doesn't wrap %v", err, beginErr)
+	}
+	if err := q.AtomicNamed("outer", func(ctx context.Context, q satomic.Querier) error {
+		if err := noop(ctx, q); err != nil {
+			return err
+		}
+		if err := q.AtomicNamed("exec", exec); err != nil {
+			return err
+		}
+		return nil
+	}); err != nil {
+		t.Error(err)
+	}
+
+	expected := []string{
+		"start outer 1",
+		"start outer/exec 2",
+		"end outer/exec 2 released",
+		"end outer 1 committed",
+	}
+	if !reflect.DeepEqual(recorder.records, expected) {
+		t.Errorf("Didn't get the expected records:\n%q\n!=\n%q", recorder.records, expected)
+	}
+
+	if err := _sqlmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOutcomeString(t *testing.T) {
+	testCases := []struct {
+		outcome  satomic.Outcome
+		expected string
+	}{
+		{outcome: satomic.OutcomeCommitted, expected: "committed"},
+		{outcome: satomic.OutcomeReleased, expected: "released"},
+		{outcome: satomic.OutcomeRolledBack, expected: "rolled_back"},
+		{outcome: satomic.OutcomeUnknown, expected: "unknown"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expected, func(t *testing.T) {
+			if s := tc.outcome.String(); s != tc.expected {
+				t.Errorf("Didn't get the expected string: %s != %s", s, tc.expected)
+			}
+		})
+	}
+}
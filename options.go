@@ -0,0 +1,195 @@
+package satomic
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+)
+
+import (
+	"github.com/dhui/satomic/savepointers"
+)
+
+var (
+	// ErrTxOptionsConflict is the canonical error value when the sql.TxOptions given to AtomicWithOptions() within a
+	// transaction conflict with the transaction's sql.TxOptions
+	ErrTxOptionsConflict = errors.New("TxOptions conflict with the transaction's TxOptions")
+	// ErrNotOutermost is the canonical error value when a durable AtomicWithOptions() call is made from a Querier
+	// that's already in a transaction
+	ErrNotOutermost = errors.New("Durable Atomic must be the outermost transaction")
+)
+
+// LabelSeparator separates the labels of nested Atomic() calls in a label path
+const LabelSeparator = "/"
+
+// AtomicOptions customizes a single call to AtomicWithOptions()
+type AtomicOptions struct {
+	// TxOptions are used to create the transaction instead of the Querier's sql.TxOptions. If nil, the Querier's
+	// sql.TxOptions are used.
+	//
+	// When a savepoint is created instead of a transaction, the transaction's sql.TxOptions can't be changed, so
+	// ErrTxOptionsConflict is returned if the TxOptions differ from the transaction's sql.TxOptions.
+	// sql.LevelDefault matches any isolation level.
+	TxOptions *sql.TxOptions
+	// Label identifies the transaction or savepoint. e.g. the business operation it's used for
+	//
+	// Labels of nested Atomic() calls form a path separated by LabelSeparator, e.g. "checkout/reserve-stock", which is
+	// used by Querier.Label(), Error.Label, HookInfo.Label and StatementInfo.Label. An empty Label inherits the label
+	// path of the Querier that AtomicWithOptions() is called on.
+	Label string
+	// Propagation determines whether a transaction or savepoint is created or the Querier's transaction is joined.
+	// Defaults to PropagationNested.
+	Propagation Propagation
+	// Durable requires the transaction to be the outermost transaction so that it's committed as soon as the
+	// callback function returns instead of being demoted to a savepoint. ErrNotOutermost is returned if the Querier
+	// is already in a transaction, unless PropagationRequiresNew is used.
+	Durable bool
+}
+
+// txOptionsCompatible determines whether or not the requested sql.TxOptions can be satisfied by a transaction
+// using the running sql.TxOptions
+func txOptionsCompatible(requested, running sql.TxOptions) bool {
+	if requested.Isolation != sql.LevelDefault && requested.Isolation != running.Isolation {
+		return false
+	}
+	return requested.ReadOnly == running.ReadOnly
+}
+
+// Option configures a Querier created by NewQuerier() or NewQuerierWithTxCreator()
+type Option func(*querier)
+
+// WithConcurrentUse makes the Querier safe for concurrent use by multiple goroutines. e.g. to run several queries in
+// parallel within an Atomic() callback function.
+//
+// Statements and savepoints are serialized on the shared *sql.Tx. Each statement locks the Querier's transaction or
+// savepoint while it runs and a nested Atomic() call keeps the Querier locked until the nested savepoint ends, so the
+// statements of concurrent nested Atomic() calls can't be interleaved. Instead of returning ErrNestedQuerierActive,
+// the Querier waits for the nested savepoint to end, so only the callback function's Querier should be used within
+// the callback function.
+//
+// Since drivers don't support running statements on a connection while rows are still being read, the rows returned
+// by Query() and QueryRow() within a transaction are read into memory before the lock is released. Use LIMIT to
+// bound the size of large results. The rows of prepared statements aren't buffered, so don't use them concurrently.
+func WithConcurrentUse() Option {
+	return func(q *querier) {
+		q.concurrent = true
+	}
+}
+
+// WithLazyBegin delays beginning the transaction until the first statement is run within an Atomic() callback
+// function, so an Atomic() call that doesn't run any statements doesn't use the database. Savepoints are also only
+// created once a statement is run within them, so nested Atomic() calls that don't run any statements don't create
+// and release savepoints.
+//
+// Since the transaction is begun by the first statement, errors beginning the transaction or creating a savepoint
+// are returned by the statement instead of by Atomic(). e.g. QueryRowContext() returns a row whose Scan() returns the
+// error.
+func WithLazyBegin() Option {
+	return func(q *querier) {
+		q.lazy = true
+	}
+}
+
+// WithRecoverPanics makes Atomic() return a panic in the callback function as an Error with a *PanicError in
+// Error.Err instead of re-panicking once the transaction or savepoint is rolled back.
+func WithRecoverPanics() Option {
+	return func(q *querier) {
+		q.recoverPanics = true
+	}
+}
+
+// WithLabeledSavepointNames names savepoints after their label path instead of using random names, so that the
+// savepoints in the database's logs are readable. e.g. "checkout_reserve_stock_1f2e3d4c" for the label path
+// "checkout/reserve-stock". Unlabeled savepoints still use random names. See savepointers.GenLabeledSavepointName()
+func WithLabeledSavepointNames() Option {
+	return func(q *querier) {
+		q.labelNames = true
+	}
+}
+
+// WithCommitVerifier uses the CommitVerifier to determine whether or not a transaction was committed when the
+// connection is lost while committing it. Without a CommitVerifier, Atomic() returns ErrCommitOutcomeUnknown.
+//
+// The CommitVerifier records the transaction's identifier as soon as the transaction is begun, so an additional
+// statement is run for every transaction.
+func WithCommitVerifier(v CommitVerifier) Option {
+	return func(q *querier) {
+		q.commitVerifier = v
+	}
+}
+
+// WithErrorClassifier classifies the errors returned by the database driver with the ErrorClassifier, so that
+// AtomicWithRetry() can retry transient errors and a lost connection can be detected while committing.
+// e.g. pqclassify.Classifier{} from github.com/dhui/satomic/savepointers/postgres/pqclassify See Classify()
+func WithErrorClassifier(c savepointers.ErrorClassifier) Option {
+	return func(q *querier) {
+		q.classifier = c
+	}
+}
+
+// WithHooks calls the Hooks throughout the lifecycle of the transactions and savepoints created by Atomic().
+// Hooks from multiple WithHooks() options are combined with ComposeHooks().
+func WithHooks(hooks ...Hooks) Option {
+	return func(q *querier) {
+		q.hooks = ComposeHooks(append([]Hooks{q.hooks}, hooks...)...)
+	}
+}
+
+// WithInterceptors runs the statements run by the Querier's ExecContext(), QueryContext() and QueryRowContext()
+// methods through the Interceptors, along with satomicx's GetContext(), SelectContext(), QueryxContext() and
+// QueryRowxContext() methods. The first Interceptor is the outermost one. Interceptors from multiple
+// WithInterceptors() options are appended.
+func WithInterceptors(interceptors ...Interceptor) Option {
+	return func(q *querier) {
+		for _, interceptor := range interceptors {
+			if interceptor != nil {
+				q.interceptors = append(q.interceptors, interceptor)
+			}
+		}
+	}
+}
+
+// WithLogger logs the statements run by Atomic() to begin, commit and roll back transactions and to create,
+// release and roll back savepoints at the debug level. Failed operations and slow transactions are logged at the
+// warn level. Each record's source is the originating caller, i.e. the caller of Atomic() or of the statement, so
+// it's logged by handlers with slog.HandlerOptions.AddSource set.
+func WithLogger(l *slog.Logger, opts LogOptions) Option {
+	return func(q *querier) {
+		if l == nil {
+			return
+		}
+		_l := &logger{logger: l, opts: opts}
+		WithHooks(_l)(q)
+		if opts.Statements {
+			WithInterceptors(_l.intercept)(q)
+		}
+	}
+}
+
+// WithRecorder records metrics for the transactions and savepoints created by Atomic() and the retries done by
+// AtomicWithRetry() with the Recorder
+func WithRecorder(r Recorder) Option {
+	return func(q *querier) {
+		if r == nil {
+			return
+		}
+		q.recorder = r
+		WithHooks(recorderHooks{recorder: r})(q)
+	}
+}
+
+// WithSQLComments adds sqlcommenter comments to the statements run by the Querier, so that statements in the
+// database's logs and statistics can be traced back to the Atomic() call that ran them.
+// e.g. UPDATE t SET x = 1 /*depth='2',label='checkout%2Freserve-stock',traceparent='00-...-01'*/;
+//
+// Comments are added to the savepoint statements run by Atomic() and the statements run through the Querier's
+// Interceptors. See WithInterceptors(). Statements that already contain a comment aren't changed. Hooks are called
+// with the savepoint statements as returned by the Savepointer.
+//
+// BEGIN, COMMIT and ROLLBACK statements are run by the database/sql driver, so they can't be commented.
+func WithSQLComments(opts SQLCommentOptions) Option {
+	return func(q *querier) {
+		q.commenter = &sqlCommenter{opts: opts}
+		WithInterceptors(q.commenter.intercept)(q)
+	}
+}
@@ -0,0 +1,634 @@
+package satomic_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+import (
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+)
+
+import (
+	"github.com/dhui/satomic"
+	"github.com/dhui/satomic/satomictest"
+	"github.com/dhui/satomic/savepointers/mock"
+)
+
+func TestQuerierAtomicWithOptions(t *testing.T) {
+	serializable := &sql.TxOptions{Isolation: sql.LevelSerializable}
+	readOnly := &sql.TxOptions{Isolation: sql.LevelSerializable, ReadOnly: true}
+
+	testCases := []struct {
+		name              string
+		mocker            func(sqlmock.Sqlmock) sqlmock.Sqlmock
+		outerOpts         *sql.TxOptions
+		innerOpts         *sql.TxOptions
+		expectedTxOpts    sql.TxOptions
+		expectedInnerErr  *satomic.Error
+		expectedInnerCall bool
+	}{
+		{name: "querier TxOptions", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectExec("RELEASE 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectCommit()
+			return m
+		}, outerOpts: nil, innerOpts: nil, expectedTxOpts: sql.TxOptions{Isolation: sql.LevelReadCommitted},
+			expectedInnerErr: nil, expectedInnerCall: true},
+		{name: "outer TxOptions", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectExec("RELEASE 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectCommit()
+			return m
+		}, outerOpts: serializable, innerOpts: nil, expectedTxOpts: *serializable, expectedInnerErr: nil,
+			expectedInnerCall: true},
+		{name: "matching inner TxOptions", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectExec("RELEASE 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectCommit()
+			return m
+		}, outerOpts: readOnly, innerOpts: &sql.TxOptions{ReadOnly: true}, expectedTxOpts: *readOnly,
+			expectedInnerErr: nil, expectedInnerCall: true},
+		{name: "conflicting isolation level", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectCommit()
+			return m
+		}, outerOpts: nil, innerOpts: serializable, expectedTxOpts: sql.TxOptions{Isolation: sql.LevelReadCommitted},
+			expectedInnerErr: satomictest.NewError(nil, satomic.ErrTxOptionsConflict), expectedInnerCall: false},
+		{name: "conflicting read only", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectCommit()
+			return m
+		}, outerOpts: serializable, innerOpts: readOnly, expectedTxOpts: *serializable,
+			expectedInnerErr: satomictest.NewError(nil, satomic.ErrTxOptionsConflict), expectedInnerCall: false},
+	}
+
+	ctx := context.Background()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, _sqlmock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal("Error creating sqlmock:", err)
+			}
+			defer db.Close() // nolint:errcheck
+
+			_sqlmock = tc.mocker(_sqlmock)
+
+			var txOpts sql.TxOptions
+			txCreator := func(ctx context.Context, db *sql.DB, opts sql.TxOptions) (*sql.Tx, error) {
+				txOpts = opts
+				return satomic.DefaultTxCreator(ctx, db, sql.TxOptions{})
+			}
+			q, err := satomic.NewQuerierWithTxCreator(ctx, db, mock.NewSavepointer(io.Discard, true),
+				sql.TxOptions{Isolation: sql.LevelReadCommitted}, txCreator)
+			if err != nil {
+				t.Fatal("Error creating Querier:", err)
+			}
+
+			innerCalled := false
+			if err := q.AtomicWithOptions(satomic.AtomicOptions{TxOptions: tc.outerOpts},
+				func(ctx context.Context, q satomic.Querier) error {
+					if err := q.AtomicWithOptions(satomic.AtomicOptions{TxOptions: tc.innerOpts},
+						func(context.Context, satomic.Querier) error {
+							innerCalled = true
+							return nil
+						}); !satomictest.ErrsEq(err, tc.expectedInnerErr) {
+						t.Errorf("Didn't get the expected error: %+v != %+v", err, tc.expectedInnerErr)
+					} else if err != nil && err.Depth != 0 {
+						// The savepoint isn't set up
+						t.Error("Didn't get the expected error depth:", err.Depth)
+					}
+					return nil
+				}); err != nil {
+				t.Error(err)
+			}
+			if txOpts != tc.expectedTxOpts {
+				t.Errorf("Didn't get the expected TxOptions: %+v != %+v", txOpts, tc.expectedTxOpts)
+			}
+			if innerCalled != tc.expectedInnerCall {
+				t.Errorf("Didn't get the expected inner call: %v != %v", innerCalled, tc.expectedInnerCall)
+			}
+
+			if err := _sqlmock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestQuerierConcurrentUse(t *testing.T) {
+	const goroutines = 20
+	nestedErr := errors.New("nested error")
+
+	db, _sqlmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal("Error creating sqlmock:", err)
+	}
+	defer db.Close() // nolint:errcheck
+
+	_sqlmock.MatchExpectationsInOrder(false)
+	_sqlmock.ExpectBegin()
+	for i := 0; i < goroutines; i++ {
+		_sqlmock.ExpectExec("UPDATE 1;").WillReturnResult(sqlmock.NewResult(0, 1))
+		_sqlmock.ExpectExec("SAVEPOINT [0-9]+;").WillReturnResult(sqlmock.NewResult(0, 0))
+		_sqlmock.ExpectExec("INSERT 1;").WillReturnResult(sqlmock.NewResult(0, 1))
+		if i%2 == 0 {
+			_sqlmock.ExpectExec("RELEASE [0-9]+;").WillReturnResult(sqlmock.NewResult(0, 0))
+		} else {
+			_sqlmock.ExpectExec("ROLLBACK TO [0-9]+;").WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+	}
+	_sqlmock.ExpectCommit()
+
+	var savepointStmts bytes.Buffer
+	ctx := context.Background()
+	q, err := satomic.NewQuerier(ctx, db, mock.NewSavepointer(&savepointStmts, true), sql.TxOptions{},
+		satomic.WithConcurrentUse())
+	if err != nil {
+		t.Fatal("Error creating Querier:", err)
+	}
+
+	var active, commits, rollbacks atomic.Int32
+	if err := q.Atomic(func(ctx context.Context, q satomic.Querier) error {
+		var wg sync.WaitGroup
+		for i := 0; i < goroutines; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if _, err := q.ExecContext(ctx, "UPDATE 1;"); err != nil {
+					t.Error(err)
+				}
+				if err := q.Atomic(func(ctx context.Context, q satomic.Querier) error {
+					if active.Add(1) > 1 {
+						t.Error("Nested savepoints are active at the same time")
+					}
+					defer active.Add(-1)
+					q.OnCommit(func(context.Context) { commits.Add(1) })
+					q.OnRollback(func(context.Context, error) { rollbacks.Add(1) })
+					if _, err := q.ExecContext(ctx, "INSERT 1;"); err != nil {
+						return err
+					}
+					if i%2 == 1 {
+						return nestedErr
+					}
+					return nil
+				}); err != nil && !errors.Is(err.Err, nestedErr) {
+					t.Error(err)
+				}
+			}(i)
+		}
+		wg.Wait()
+		return nil
+	}); err != nil {
+		t.Error(err)
+	}
+
+	if n := commits.Load(); n != goroutines/2 {
+		t.Errorf("Didn't get the expected number of commit callbacks: %d != %d", n, goroutines/2)
+	}
+	if n := rollbacks.Load(); n != goroutines/2 {
+		t.Errorf("Didn't get the expected number of rollback callbacks: %d != %d", n, goroutines/2)
+	}
+
+	// Each savepoint should be created and ended before the next one is created
+	stmts := strings.Split(strings.TrimSpace(savepointStmts.String()), "\n")
+	if len(stmts) != 2*goroutines {
+		t.Fatalf("Didn't get the expected number of savepoint statements: %d != %d", len(stmts), 2*goroutines)
+	}
+	for i := 0; i < len(stmts); i += 2 {
+		name := strings.TrimPrefix(stmts[i], "SAVEPOINT ")
+		if stmts[i+1] != "RELEASE "+name && stmts[i+1] != "ROLLBACK TO "+name {
+			t.Errorf("Savepoint statements were interleaved: %q, %q", stmts[i], stmts[i+1])
+		}
+	}
+
+	if err := _sqlmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQuerierConcurrentUseQueries(t *testing.T) {
+	const goroutines = 20
+
+	db, _sqlmock, err := satomictest.NewBusyConnDB()
+	if err != nil {
+		t.Fatal("Error creating sqlmock:", err)
+	}
+	defer db.Close() // nolint:errcheck
+
+	_sqlmock.MatchExpectationsInOrder(false)
+	_sqlmock.ExpectBegin()
+	for i := 0; i < goroutines; i++ {
+		_sqlmock.ExpectExec("UPDATE 1;").WillReturnResult(sqlmock.NewResult(0, 1))
+		_sqlmock.ExpectQuery("SELECT 1;").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2).AddRow(3))
+		_sqlmock.ExpectQuery("SELECT 2;").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	}
+	_sqlmock.ExpectCommit()
+
+	ctx := context.Background()
+	q, err := satomic.NewQuerier(ctx, db, mock.NewSavepointer(io.Discard, true), sql.TxOptions{},
+		satomic.WithConcurrentUse())
+	if err != nil {
+		t.Fatal("Error creating Querier:", err)
+	}
+
+	// Statements run by other goroutines while rows are being read fail with satomictest.ErrConnBusy
+	if err := q.Atomic(func(ctx context.Context, q satomic.Querier) error {
+		var wg sync.WaitGroup
+		for i := 0; i < goroutines; i++ {
+			wg.Add(3)
+			go func() {
+				defer wg.Done()
+				if _, err := q.ExecContext(ctx, "UPDATE 1;"); err != nil {
+					t.Error(err)
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				rows, err := q.QueryContext(ctx, "SELECT 1;")
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				defer rows.Close() // nolint:errcheck
+				var ids []int
+				for rows.Next() {
+					var id int
+					if err := rows.Scan(&id); err != nil {
+						t.Error(err)
+					}
+					ids = append(ids, id)
+					// Give the other goroutines a chance to run statements while the rows are being read
+					time.Sleep(time.Millisecond)
+				}
+				if err := rows.Err(); err != nil {
+					t.Error(err)
+				}
+				if !reflect.DeepEqual(ids, []int{1, 2, 3}) {
+					t.Errorf("Didn't get the expected ids: %v", ids)
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				var id int
+				if err := q.QueryRowContext(ctx, "SELECT 2;").Scan(&id); err != nil {
+					t.Error(err)
+				} else if id != 4 {
+					t.Errorf("Didn't get the expected id: %d != 4", id)
+				}
+			}()
+		}
+		wg.Wait()
+		return nil
+	}); err != nil {
+		t.Error(err)
+	}
+
+	if err := _sqlmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQuerierAtomicDurable(t *testing.T) {
+	testCases := []struct {
+		name         string
+		mocker       func(sqlmock.Sqlmock) sqlmock.Sqlmock
+		nested       bool
+		propagation  satomic.Propagation
+		expectedErr  *satomic.Error
+		expectedCall bool
+	}{
+		{name: "outermost", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectCommit()
+			return m
+		}, nested: false, expectedErr: nil, expectedCall: true},
+		{name: "nested", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectCommit()
+			return m
+		}, nested: true, expectedErr: satomictest.NewError(nil, satomic.ErrNotOutermost), expectedCall: false},
+		{name: "nested - requires new", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectBegin()
+			m.ExpectCommit()
+			m.ExpectCommit()
+			return m
+		}, nested: true, propagation: satomic.PropagationRequiresNew, expectedErr: nil, expectedCall: true},
+	}
+
+	ctx := context.Background()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, _sqlmock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal("Error creating sqlmock:", err)
+			}
+			defer db.Close() // nolint:errcheck
+
+			_sqlmock = tc.mocker(_sqlmock)
+
+			q, err := satomic.NewQuerier(ctx, db, mock.NewSavepointer(io.Discard, true), sql.TxOptions{})
+			if err != nil {
+				t.Fatal("Error creating Querier:", err)
+			}
+
+			called := false
+			durable := func(q satomic.Querier) *satomic.Error {
+				return q.AtomicWithOptions(satomic.AtomicOptions{Durable: true, Propagation: tc.propagation},
+					func(context.Context, satomic.Querier) error {
+						called = true
+						return nil
+					})
+			}
+			var durableErr *satomic.Error
+			if tc.nested {
+				if err := q.Atomic(func(ctx context.Context, q satomic.Querier) error {
+					durableErr = durable(q)
+					return nil
+				}); err != nil {
+					t.Error(err)
+				}
+			} else {
+				durableErr = durable(q)
+			}
+			if !satomictest.ErrsEq(durableErr, tc.expectedErr) {
+				t.Errorf("Didn't get the expected error: %+v != %+v", durableErr, tc.expectedErr)
+			}
+			if called != tc.expectedCall {
+				t.Errorf("Didn't get the expected call: %v != %v", called, tc.expectedCall)
+			}
+
+			if err := _sqlmock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestQuerierLazyBegin(t *testing.T) {
+	beginErr := errors.New("begin error")
+	cbErr := errors.New("callback error")
+
+	exec := func(ctx context.Context, q satomic.Querier) error {
+		_, err := q.ExecContext(ctx, "UPDATE 1;")
+		return err
+	}
+	queryRow := func(ctx context.Context, q satomic.Querier) error {
+		var id int
+		return q.QueryRowContext(ctx, "SELECT 1;").Scan(&id)
+	}
+	nested := func(f func(context.Context, satomic.Querier) error) func(context.Context, satomic.Querier) error {
+		return func(ctx context.Context, q satomic.Querier) error {
+			if err := q.Atomic(f); err != nil {
+				return err
+			}
+			return nil
+		}
+	}
+
+	testCases := []struct {
+		name           string
+		mocker         func(sqlmock.Sqlmock) sqlmock.Sqlmock
+		f              func(context.Context, satomic.Querier) error
+		expectedErr    *satomic.Error
+		expectedCommit bool
+	}{
+		{name: "no statements", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock { return m },
+			f: func(context.Context, satomic.Querier) error { return nil }, expectedErr: nil, expectedCommit: true},
+		{name: "no statements - nested", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock { return m },
+			f: nested(func(context.Context, satomic.Querier) error { return nil }), expectedErr: nil,
+			expectedCommit: true},
+		{name: "no statements - error", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock { return m },
+			f: func(context.Context, satomic.Querier) error { return cbErr }, expectedErr: satomictest.NewError(cbErr, nil),
+			expectedCommit: false},
+		{name: "statement", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("UPDATE 1;").WillReturnResult(sqlmock.NewResult(0, 1))
+			m.ExpectCommit()
+			return m
+		}, f: exec, expectedErr: nil, expectedCommit: true},
+		{name: "statement - begin error", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin().WillReturnError(beginErr)
+			return m
+		}, f: exec, expectedErr: satomictest.NewError(beginErr, nil), expectedCommit: false},
+		{name: "query row - begin error", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin().WillReturnError(beginErr)
+			return m
+		}, f: queryRow, expectedErr: satomictest.NewError(beginErr, nil), expectedCommit: false},
+		{name: "nested query row - savepoint error", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("SAVEPOINT 1;").WillReturnError(beginErr)
+			m.ExpectRollback()
+			return m
+		}, f: func(ctx context.Context, q satomic.Querier) error {
+			if err := q.Atomic(queryRow); !errors.Is(err, beginErr) {
+				t.Errorf("Didn't get the expected nested error: %+v doesn't wrap %v", err, beginErr)
+			}
+			return cbErr
+		}, expectedErr: satomictest.NewError(cbErr, nil), expectedCommit: false},
+		{name: "statement - nested no statements - error", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("UPDATE 1;").WillReturnResult(sqlmock.NewResult(0, 1))
+			m.ExpectCommit()
+			return m
+		}, f: func(ctx context.Context, q satomic.Querier) error {
+			if err := exec(ctx, q); err != nil {
+				return err
+			}
+			expectedErr := satomictest.NewError(cbErr, nil)
+			if err := q.Atomic(func(context.Context, satomic.Querier) error {
+				return cbErr
+			}); !satomictest.ErrsEq(err, expectedErr) {
+				t.Errorf("Didn't get the expected nested error: %+v != %+v", err, expectedErr)
+			}
+			return nil
+		}, expectedErr: nil, expectedCommit: true},
+		{name: "nested statement", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectExec("UPDATE 1;").WillReturnResult(sqlmock.NewResult(0, 1))
+			m.ExpectExec("RELEASE 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectCommit()
+			return m
+		}, f: nested(exec), expectedErr: nil, expectedCommit: true},
+		{name: "nested statement - error", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectExec("UPDATE 1;").WillReturnResult(sqlmock.NewResult(0, 1))
+			m.ExpectExec("ROLLBACK TO 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectCommit()
+			return m
+		}, f: func(ctx context.Context, q satomic.Querier) error {
+			expectedErr := satomictest.NewError(cbErr, nil)
+			if err := q.Atomic(func(ctx context.Context, q satomic.Querier) error {
+				if err := exec(ctx, q); err != nil {
+					return err
+				}
+				return cbErr
+			}); !satomictest.ErrsEq(err, expectedErr) {
+				t.Errorf("Didn't get the expected nested error: %+v != %+v", err, expectedErr)
+			}
+			return nil
+		}, expectedErr: nil, expectedCommit: true},
+	}
+
+	ctx := context.Background()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, _sqlmock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal("Error creating sqlmock:", err)
+			}
+			defer db.Close() // nolint:errcheck
+
+			_sqlmock = tc.mocker(_sqlmock)
+
+			q, err := satomic.NewQuerier(ctx, db, mock.NewSavepointer(io.Discard, true), sql.TxOptions{},
+				satomic.WithLazyBegin())
+			if err != nil {
+				t.Fatal("Error creating Querier:", err)
+			}
+
+			committed := false
+			if err := q.Atomic(func(ctx context.Context, q satomic.Querier) error {
+				q.OnCommit(func(context.Context) { committed = true })
+				return tc.f(ctx, q)
+			}); !satomictest.ErrsEq(err, tc.expectedErr) {
+				t.Errorf("Didn't get the expected error: %+v != %+v", err, tc.expectedErr)
+			}
+			if committed != tc.expectedCommit {
+				t.Errorf("Didn't get the expected commit: %v != %v", committed, tc.expectedCommit)
+			}
+
+			if err := _sqlmock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestQuerierRecoverPanics(t *testing.T) {
+	const panicVal = "whoa!"
+	rbErr := errors.New("rollback error")
+
+	testCases := []struct {
+		name           string
+		mocker         func(sqlmock.Sqlmock) sqlmock.Sqlmock
+		recoverPanics  bool
+		savepoint      bool
+		expectedAtomic error
+	}{
+		{name: "transaction", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectRollback()
+			return m
+		}, recoverPanics: true, savepoint: false, expectedAtomic: nil},
+		{name: "transaction - rollback error", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectRollback().WillReturnError(rbErr)
+			return m
+		}, recoverPanics: true, savepoint: false, expectedAtomic: rbErr},
+		{name: "savepoint", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectExec("ROLLBACK TO 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectCommit()
+			return m
+		}, recoverPanics: true, savepoint: true, expectedAtomic: nil},
+		{name: "savepoint - rollback error", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectExec("ROLLBACK TO 1;").WillReturnError(rbErr)
+			m.ExpectCommit()
+			return m
+		}, recoverPanics: true, savepoint: true, expectedAtomic: rbErr},
+		{name: "re-panic - transaction - rollback error", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectRollback().WillReturnError(rbErr)
+			return m
+		}, recoverPanics: false, savepoint: false},
+		{name: "re-panic - savepoint - rollback error", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectExec("ROLLBACK TO 1;").WillReturnError(rbErr)
+			m.ExpectRollback()
+			return m
+		}, recoverPanics: false, savepoint: true},
+	}
+
+	ctx := context.Background()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, _sqlmock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal("Error creating sqlmock:", err)
+			}
+			defer db.Close() // nolint:errcheck
+
+			_sqlmock = tc.mocker(_sqlmock)
+
+			var opts []satomic.Option
+			if tc.recoverPanics {
+				opts = append(opts, satomic.WithRecoverPanics())
+			}
+			q, err := satomic.NewQuerier(ctx, db, mock.NewSavepointer(io.Discard, true), sql.TxOptions{},
+				opts...)
+			if err != nil {
+				t.Fatal("Error creating Querier:", err)
+			}
+
+			panics := func(context.Context, satomic.Querier) error { panic(panicVal) }
+			var panicErr *satomic.Error
+			func() {
+				defer func() {
+					r := recover()
+					if tc.recoverPanics && r != nil {
+						t.Error("Unexpected panic:", r)
+					} else if !tc.recoverPanics && r != panicVal {
+						t.Errorf("Didn't get the expected panic value: %+v != %+v", r, panicVal)
+					}
+				}()
+				if tc.savepoint {
+					if err := q.Atomic(func(ctx context.Context, q satomic.Querier) error {
+						panicErr = q.Atomic(panics)
+						return nil
+					}); err != nil {
+						t.Error(err)
+					}
+				} else {
+					panicErr = q.Atomic(panics)
+				}
+			}()
+
+			if tc.recoverPanics {
+				if panicErr == nil {
+					t.Fatal("Expected an error")
+				}
+				var pe *satomic.PanicError
+				if !errors.As(panicErr.Err, &pe) || pe.Value != panicVal || len(pe.Stack) == 0 {
+					t.Errorf("Didn't get the expected PanicError: %+v", panicErr.Err)
+				}
+				if panicErr.Atomic != tc.expectedAtomic {
+					t.Errorf("Didn't get the expected Atomic error: %+v != %+v", panicErr.Atomic, tc.expectedAtomic)
+				}
+			}
+
+			if err := _sqlmock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
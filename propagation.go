@@ -0,0 +1,59 @@
+package satomic
+
+import (
+	"errors"
+)
+
+var (
+	// ErrNoTransaction is the canonical error value when PropagationMandatory is used with a Querier that isn't in
+	// a transaction
+	ErrNoTransaction = errors.New("Querier isn't in a transaction")
+	// ErrExistingTransaction is the canonical error value when PropagationNever is used with a Querier that's in a
+	// transaction
+	ErrExistingTransaction = errors.New("Querier is already in a transaction")
+)
+
+// Propagation determines how AtomicWithOptions() uses the Querier's transaction, if any
+type Propagation int
+
+const (
+	// PropagationNested creates a savepoint if the Querier is in a transaction. Otherwise, a transaction is created.
+	// This is the default and is how Atomic() behaves.
+	PropagationNested Propagation = iota
+	// PropagationRequired joins the Querier's transaction without creating a savepoint. Otherwise, a transaction is
+	// created. Since there's no savepoint, the enclosing savepoint or transaction is marked rollback-only when the
+	// callback function returns an error within a transaction. See SetSavepointRollbackOnly()
+	PropagationRequired
+	// PropagationRequiresNew always creates a new transaction using the TxCreator. The new transaction is
+	// independent of the Querier's transaction, which stays open and isn't affected by the new transaction's commit
+	// or rollback. e.g. to record an audit log that survives the rollback of the Querier's transaction
+	PropagationRequiresNew
+	// PropagationMandatory is like PropagationRequired but returns ErrNoTransaction if the Querier isn't in a
+	// transaction
+	PropagationMandatory
+	// PropagationNever runs the callback function outside of a transaction, so each statement is committed on its
+	// own. ErrExistingTransaction is returned if the Querier is in a transaction.
+	PropagationNever
+)
+
+func (p Propagation) String() string {
+	switch p {
+	case PropagationNested:
+		return "nested"
+	case PropagationRequired:
+		return "required"
+	case PropagationRequiresNew:
+		return "requires new"
+	case PropagationMandatory:
+		return "mandatory"
+	case PropagationNever:
+		return "never"
+	default:
+		return "unknown"
+	}
+}
+
+// usesTx determines whether or not the Propagation uses the Querier's transaction
+func (p Propagation) usesTx() bool {
+	return p != PropagationRequiresNew && p != PropagationNever
+}
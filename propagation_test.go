@@ -0,0 +1,213 @@
+package satomic_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"testing"
+)
+
+import (
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+)
+
+import (
+	"github.com/dhui/satomic"
+	"github.com/dhui/satomic/satomictest"
+	"github.com/dhui/satomic/savepointers/mock"
+)
+
+func TestQuerierAtomicPropagation(t *testing.T) {
+	cbErr := errors.New("callback error")
+
+	atomic := func(propagation satomic.Propagation, err error) func(context.Context,
+		satomic.Querier) *satomic.Error {
+		return func(ctx context.Context, q satomic.Querier) *satomic.Error {
+			return q.AtomicWithOptions(satomic.AtomicOptions{Propagation: propagation},
+				func(ctx context.Context, q satomic.Querier) error {
+					if _, err := q.ExecContext(ctx, "UPDATE 1;"); err != nil {
+						return err
+					}
+					return err
+				})
+		}
+	}
+	// inTx runs f in a transaction and returns f's error, which is not returned by the transaction's callback
+	// function unless rollback is set
+	inTx := func(f func(context.Context, satomic.Querier) *satomic.Error,
+		rollback bool) func(context.Context, satomic.Querier) *satomic.Error {
+		return func(ctx context.Context, q satomic.Querier) *satomic.Error {
+			var fErr *satomic.Error
+			if err := q.Atomic(func(ctx context.Context, q satomic.Querier) error {
+				fErr = f(ctx, q)
+				if rollback {
+					return errors.New("rollback")
+				}
+				return nil
+			}); err != nil && !rollback {
+				t.Error("Transaction error:", err)
+			}
+			return fErr
+		}
+	}
+
+	testCases := []struct {
+		name        string
+		mocker      func(sqlmock.Sqlmock) sqlmock.Sqlmock
+		run         func(context.Context, satomic.Querier) *satomic.Error
+		expectedErr *satomic.Error
+	}{
+		{name: "nested", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectExec("UPDATE 1;").WillReturnResult(sqlmock.NewResult(0, 1))
+			m.ExpectExec("ROLLBACK TO 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectCommit()
+			return m
+		}, run: inTx(atomic(satomic.PropagationNested, cbErr), false),
+			expectedErr: satomictest.NewError(cbErr, nil)},
+		{name: "required - no tx", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("UPDATE 1;").WillReturnResult(sqlmock.NewResult(0, 1))
+			m.ExpectCommit()
+			return m
+		}, run: atomic(satomic.PropagationRequired, nil), expectedErr: nil},
+		{name: "required - in tx", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("UPDATE 1;").WillReturnResult(sqlmock.NewResult(0, 1))
+			m.ExpectCommit()
+			return m
+		}, run: inTx(atomic(satomic.PropagationRequired, nil), false), expectedErr: nil},
+		{name: "required - in tx - error", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("UPDATE 1;").WillReturnResult(sqlmock.NewResult(0, 1))
+			m.ExpectRollback()
+			return m
+		}, run: inTx(atomic(satomic.PropagationRequired, cbErr), true),
+			expectedErr: satomictest.NewError(cbErr, nil)},
+		{name: "requires new - no tx", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("UPDATE 1;").WillReturnResult(sqlmock.NewResult(0, 1))
+			m.ExpectCommit()
+			return m
+		}, run: atomic(satomic.PropagationRequiresNew, nil), expectedErr: nil},
+		{name: "requires new - in tx", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectBegin()
+			m.ExpectExec("UPDATE 1;").WillReturnResult(sqlmock.NewResult(0, 1))
+			m.ExpectCommit()
+			m.ExpectRollback()
+			return m
+		}, run: inTx(atomic(satomic.PropagationRequiresNew, nil), true), expectedErr: nil},
+		{name: "requires new - in tx - error", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectBegin()
+			m.ExpectExec("UPDATE 1;").WillReturnResult(sqlmock.NewResult(0, 1))
+			m.ExpectRollback()
+			m.ExpectCommit()
+			return m
+		}, run: inTx(atomic(satomic.PropagationRequiresNew, cbErr), false),
+			expectedErr: satomictest.NewError(cbErr, nil)},
+		{name: "mandatory - no tx", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock { return m },
+			run:         atomic(satomic.PropagationMandatory, nil),
+			expectedErr: satomictest.NewError(nil, satomic.ErrNoTransaction)},
+		{name: "mandatory - in tx", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("UPDATE 1;").WillReturnResult(sqlmock.NewResult(0, 1))
+			m.ExpectCommit()
+			return m
+		}, run: inTx(atomic(satomic.PropagationMandatory, nil), false), expectedErr: nil},
+		{name: "never - no tx", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectExec("UPDATE 1;").WillReturnResult(sqlmock.NewResult(0, 1))
+			return m
+		}, run: atomic(satomic.PropagationNever, cbErr), expectedErr: satomictest.NewError(cbErr, nil)},
+		{name: "never - in tx", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectCommit()
+			return m
+		}, run: inTx(atomic(satomic.PropagationNever, nil), false),
+			expectedErr: satomictest.NewError(nil, satomic.ErrExistingTransaction)},
+	}
+
+	ctx := context.Background()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, _sqlmock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal("Error creating sqlmock:", err)
+			}
+			defer db.Close() // nolint:errcheck
+
+			_sqlmock = tc.mocker(_sqlmock)
+
+			q, err := satomic.NewQuerier(ctx, db, mock.NewSavepointer(io.Discard, true), sql.TxOptions{})
+			if err != nil {
+				t.Fatal("Error creating Querier:", err)
+			}
+			if err := tc.run(ctx, q); !satomictest.ErrsEq(err, tc.expectedErr) {
+				t.Errorf("Didn't get the expected error: %+v != %+v", err, tc.expectedErr)
+			}
+
+			if err := _sqlmock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestQuerierAtomicPropagationIntrospection(t *testing.T) {
+	db, _sqlmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal("Error creating sqlmock:", err)
+	}
+	defer db.Close() // nolint:errcheck
+
+	_sqlmock.ExpectBegin()
+	_sqlmock.ExpectBegin()
+	_sqlmock.ExpectCommit()
+	_sqlmock.ExpectCommit()
+
+	ctx := context.Background()
+	q, err := satomic.NewQuerier(ctx, db, mock.NewSavepointer(io.Discard, true), sql.TxOptions{})
+	if err != nil {
+		t.Fatal("Error creating Querier:", err)
+	}
+
+	if err := q.Atomic(func(ctx context.Context, txQ satomic.Querier) error {
+		if err := txQ.AtomicWithOptions(satomic.AtomicOptions{Propagation: satomic.PropagationRequired},
+			func(ctx context.Context, q satomic.Querier) error {
+				if q.Tx() != txQ.Tx() || q.Depth() != 1 || q.SavepointName() != "" {
+					t.Errorf("Didn't join the transaction. Depth: %d, SavepointName: %q", q.Depth(),
+						q.SavepointName())
+				}
+				// The joined transaction's Querier can't be used until the callback function returns
+				if err := txQ.Err(); err != satomic.ErrNestedQuerierActive {
+					t.Errorf("Didn't get the expected error: %+v != %+v", err, satomic.ErrNestedQuerierActive)
+				}
+				return nil
+			}); err != nil {
+			return err
+		}
+		if err := txQ.AtomicWithOptions(satomic.AtomicOptions{Propagation: satomic.PropagationRequiresNew},
+			func(ctx context.Context, q satomic.Querier) error {
+				if q.Tx() == txQ.Tx() || q.Depth() != 1 {
+					t.Errorf("Didn't create a new transaction. Depth: %d", q.Depth())
+				}
+				// The outer transaction's Querier can still be used
+				if err := txQ.Err(); err != nil {
+					t.Error(err)
+				}
+				return nil
+			}); err != nil {
+			return err
+		}
+		return nil
+	}); err != nil {
+		t.Error(err)
+	}
+
+	if err := _sqlmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -5,9 +5,13 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"runtime/debug"
+	"time"
 )
 
 import (
+	"github.com/dhui/satomic/internal/bufsql"
+	"github.com/dhui/satomic/internal/errsql"
 	"github.com/dhui/satomic/savepointers"
 )
 
@@ -21,6 +25,12 @@ var (
 	ErrNilQuerier = errors.New("nil Querier")
 	// ErrInvalidQuerier is the canonical error value for when an invalid Querier is used
 	ErrInvalidQuerier = errors.New("Invalid Querier")
+	// ErrQuerierClosed is the canonical error value for when a Querier is used after its transaction or savepoint
+	// has ended. e.g. the Querier passed to an Atomic() callback function is used after the callback function returns
+	ErrQuerierClosed = errors.New("Querier's transaction or savepoint has ended")
+	// ErrNestedQuerierActive is the canonical error value for when a Querier is used while one of its nested
+	// savepoints is still active. i.e. only the Querier passed to the innermost Atomic() callback function can be used
+	ErrNestedQuerierActive = errors.New("Querier has an active nested savepoint")
 )
 
 // QuerierBase provides an interface containing database/sql methods shared between
@@ -32,33 +42,125 @@ type QuerierBase interface {
 	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
 	QueryRow(query string, args ...interface{}) *sql.Row
 	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
-	// TODO:
-	// Should support for Prepare(), PrepareContext(), Stmt(), and StmtContext() be added?
+	Prepare(query string) (*sql.Stmt, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
 }
 
 // Querier provides an interface to interact with a SQL DB within an atomic transaction or savepoint
+//
+// The *sql.Row returned by QueryRow() and QueryRowContext() is never nil. If the statement can't be run, e.g. because
+// the Querier is closed or the transaction can't be begun, the error is returned by the row's Err() and Scan().
 type Querier interface {
 	QuerierBase
 
+	// Err returns an error if the Querier can't be used. e.g. ErrQuerierClosed or ErrNestedQuerierActive
+	Err() error
+
+	// InTransaction determines whether or not the Querier is in a transaction
+	InTransaction() bool
+	// Depth returns how deeply the Querier is nested. 0 if the Querier isn't in a transaction, 1 if it's in a
+	// transaction and 1 more for each savepoint
+	Depth() int
+	// SavepointName returns the name of the Querier's savepoint or "" if the Querier isn't in a savepoint
+	SavepointName() string
+	// Label returns the label path of the Querier's transaction or savepoint, e.g. "checkout/reserve-stock", or ""
+	// if none of the Atomic() calls that the Querier is nested in are labeled. See AtomicOptions.Label
+	Label() string
+	// TxOptions returns the sql.TxOptions of the Querier's transaction. If the Querier isn't in a transaction, the
+	// sql.TxOptions used to create new transactions are returned.
+	TxOptions() sql.TxOptions
+	// Tx returns the Querier's transaction or nil if the Querier isn't in a transaction. With WithLazyBegin(), the
+	// transaction is begun if it hasn't been already and nil is returned if it can't be begun.
+	// Statements run directly on the transaction bypass the Querier, so only use it when the Querier can't be used.
+	Tx() *sql.Tx
+
+	// Stmt returns a transaction-specific prepared statement from an existing statement prepared on the DB.
+	// The statement is shared by all of the savepoints within the transaction and is closed when the transaction
+	// is committed or rolled back. If the Querier isn't in a transaction, the given statement is returned.
+	// If the Querier can't be used, the returned statement's methods return the error. e.g. ErrQuerierClosed
+	//
+	// Statements prepared with Prepare() or PrepareContext() within an Atomic() callback function are closed when
+	// the callback function's transaction or savepoint ends.
+	Stmt(stmt *sql.Stmt) *sql.Stmt
+	// StmtContext is like Stmt() but with a context
+	StmtContext(ctx context.Context, stmt *sql.Stmt) *sql.Stmt
+
 	// Atomic runs any SQL statement(s) with the given querier atomicly by wrapping the statement(s)
 	// in a transaction or savepoint.
 	// Any error returned by the callback function (or panic) will result in the rollback of the transaction
-	// or rollback to the previous savepoint as appropriate.
+	// or rollback to the previous savepoint as appropriate. Return ErrRollback to roll back without Atomic()
+	// returning an error. Panics are re-panicked after the rollback unless the Querier is created with
+	// WithRecoverPanics().
 	// Otherwise, the previous savepoint will be released or the transaction will be committed.
+	// The context passed to the callback function carries the callback function's Querier.
+	// See QuerierFromContext().
+	//
+	// The callback function's Querier can't be used once the callback function returns and the Querier that
+	// Atomic() is called on can't be used until the callback function returns. Doing so returns ErrQuerierClosed or
+	// ErrNestedQuerierActive respectively.
 	//
-	// Note: Atomic() is not safe for concurrent use by multiple goroutines. e.g. your SQL statements may be
-	// interleaved and thus nonsensical.
+	// Note: Atomic() is not safe for concurrent use by multiple goroutines unless the Querier is created with
+	// WithConcurrentUse(). e.g. your SQL statements may be interleaved and thus nonsensical.
 	Atomic(f func(context.Context, Querier) error) *Error
+	// AtomicWithOptions is like Atomic() but allows the transaction's options to be specified for each call instead
+	// of using the sql.TxOptions the Querier was created with. The Propagation option determines whether a
+	// transaction or savepoint is created or the Querier's transaction is joined.
+	AtomicWithOptions(opts AtomicOptions, f func(context.Context, Querier) error) *Error
+	// AtomicNamed is like Atomic() but labels the transaction or savepoint. See AtomicOptions.Label
+	AtomicNamed(label string, f func(context.Context, Querier) error) *Error
+	// AtomicWithRetry is like Atomic() but re-runs the callback function in a new transaction if the error is
+	// retryable according to the given RetryPolicy. e.g. serialization failures and deadlocks
+	AtomicWithRetry(policy RetryPolicy, f func(context.Context, Querier) error) *Error
+
+	// SetRollbackOnly marks the Querier's transaction to be rolled back instead of committed, even if every callback
+	// function returns nil. The outermost Atomic() call reports the rollback with ErrRollbackOnly in Error.Atomic.
+	// If the Querier isn't in a transaction, SetRollbackOnly does nothing.
+	SetRollbackOnly()
+	// SetSavepointRollbackOnly is like SetRollbackOnly() but only marks the Querier's savepoint to be rolled back
+	// instead of released. If the Querier isn't in a savepoint, the transaction is marked.
+	SetSavepointRollbackOnly()
+
+	// OnCommit registers a function to be called after the outermost transaction is committed.
+	// Functions registered within a savepoint are discarded if the savepoint is rolled back.
+	// If the Querier isn't in a transaction, the function is called immediately.
+	// If the Querier's transaction or savepoint has ended, i.e. Err() returns ErrQuerierClosed, the function is
+	// discarded. The same goes for OnRollback() and OnCommitOutcomeUnknown().
+	OnCommit(f func(context.Context))
+	// OnRollback registers a function to be called after the transaction or savepoint that the Querier is in is
+	// rolled back. The function is called with the error that caused the rollback.
+	// Functions registered within a savepoint that's released are called if the transaction is rolled back.
+	// If the Querier isn't in a transaction, the function is never called.
+	// Neither the OnCommit() nor the OnRollback() functions are called if the transaction's commit outcome is unknown.
+	// See OnCommitOutcomeUnknown()
+	OnRollback(f func(context.Context, error))
+	// OnCommitOutcomeUnknown registers a function to be called instead of the OnCommit() and OnRollback() functions if
+	// the connection is lost while committing the outermost transaction, so it may or may not have been committed.
+	// The function is called with the error returned in Error.Atomic, which wraps ErrCommitOutcomeUnknown.
+	// Functions registered within a savepoint are discarded if the savepoint is rolled back.
+	// If the Querier isn't in a transaction, the function is never called.
+	OnCommitOutcomeUnknown(f func(context.Context, error))
 }
 
 type querier struct {
-	ctx           context.Context
-	db            *sql.DB
-	txCreator     TxCreator
-	txOpts        sql.TxOptions
-	tx            *sql.Tx
-	savepointer   savepointers.Savepointer
-	savepointName string
+	ctx            context.Context
+	db             *sql.DB
+	txCreator      TxCreator
+	txOpts         sql.TxOptions
+	tx             *sql.Tx
+	savepointer    savepointers.Savepointer
+	savepointName  string
+	scope          *scope
+	label          string
+	labelNames     bool
+	concurrent     bool
+	lazy           bool
+	recoverPanics  bool
+	commitVerifier CommitVerifier
+	classifier     savepointers.ErrorClassifier
+	hooks          Hooks
+	interceptors   []Interceptor
+	recorder       Recorder
+	commenter      *sqlCommenter
 }
 
 func (q *querier) Exec(query string, args ...interface{}) (sql.Result, error) {
@@ -72,10 +174,25 @@ func (q *querier) ExecContext(ctx context.Context, query string, args ...interfa
 	if q.db == nil {
 		return nil, ErrInvalidQuerier
 	}
-	if q.tx == nil {
-		return q.db.ExecContext(ctx, query, args...)
+	tx, release, err := q.acquireTx()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	var result sql.Result
+	err = q.intercept(ctx, "ExecContext", query, args, func(ctx context.Context, query string,
+		args ...interface{}) (execErr error) {
+		if tx == nil {
+			result, execErr = q.db.ExecContext(ctx, query, args...)
+		} else {
+			result, execErr = tx.ExecContext(ctx, query, args...)
+		}
+		return execErr
+	})
+	if err != nil {
+		return nil, err
 	}
-	return q.tx.ExecContext(ctx, query, args...)
+	return result, nil
 }
 
 func (q *querier) Query(query string, args ...interface{}) (*sql.Rows, error) {
@@ -89,10 +206,32 @@ func (q *querier) QueryContext(ctx context.Context, query string, args ...interf
 	if q.db == nil {
 		return nil, ErrInvalidQuerier
 	}
-	if q.tx == nil {
-		return q.db.QueryContext(ctx, query, args...)
+	tx, release, err := q.acquireTx()
+	if err != nil {
+		return nil, err
 	}
-	return q.tx.QueryContext(ctx, query, args...)
+	defer release()
+	var rows *sql.Rows
+	err = q.intercept(ctx, "QueryContext", query, args, func(ctx context.Context, query string,
+		args ...interface{}) (queryErr error) {
+		if tx == nil {
+			rows, queryErr = q.db.QueryContext(ctx, query, args...)
+		} else {
+			rows, queryErr = tx.QueryContext(ctx, query, args...)
+			if queryErr == nil && q.concurrent {
+				// The transaction can be used by other goroutines once it's released, so the rows are read first
+				rows, queryErr = bufsql.Rows(ctx, bufsql.Read(rows))
+			}
+		}
+		return queryErr
+	})
+	if err != nil {
+		if rows != nil {
+			rows.Close() // nolint:errcheck
+		}
+		return nil, err
+	}
+	return rows, nil
 }
 func (q *querier) QueryRow(query string, args ...interface{}) *sql.Row {
 	return q.QueryRowContext(context.Background(), query, args...)
@@ -100,19 +239,104 @@ func (q *querier) QueryRow(query string, args ...interface{}) *sql.Row {
 
 func (q *querier) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
 	if q == nil {
-		return nil
+		return errsql.Row(ErrNilQuerier)
 	}
 	if q.db == nil {
-		return nil
+		return errsql.Row(ErrInvalidQuerier)
 	}
-	if q.tx == nil {
-		return q.db.QueryRowContext(ctx, query, args...)
+	tx, release, err := q.acquireTx()
+	if err != nil {
+		return errsql.Row(err)
 	}
-	return q.tx.QueryRowContext(ctx, query, args...)
+	defer release()
+	var row *sql.Row
+	err = q.intercept(ctx, "QueryRowContext", query, args, func(ctx context.Context, query string,
+		args ...interface{}) error {
+		switch {
+		case tx == nil:
+			row = q.db.QueryRowContext(ctx, query, args...)
+		case q.concurrent:
+			// The transaction can be used by other goroutines once it's released, so the row is read first
+			rows, queryErr := tx.QueryContext(ctx, query, args...)
+			if queryErr != nil {
+				row = errsql.Row(queryErr)
+			} else {
+				row = bufsql.Row(ctx, bufsql.Read(rows))
+			}
+		default:
+			row = tx.QueryRowContext(ctx, query, args...)
+		}
+		return row.Err()
+	})
+	// The row's error is reported by Scan(). Any other error means the statement wasn't run.
+	if row == nil || (err != nil && !errors.Is(err, row.Err())) {
+		if err == nil {
+			// An Interceptor skipped the statement without an error, so there's no row
+			err = sql.ErrNoRows
+		}
+		return errsql.Row(err)
+	}
+	return row
+}
+
+func (q *querier) Prepare(query string) (*sql.Stmt, error) {
+	return q.PrepareContext(context.Background(), query)
+}
+
+func (q *querier) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	if q == nil {
+		return nil, ErrNilQuerier
+	}
+	if q.db == nil {
+		return nil, ErrInvalidQuerier
+	}
+	tx, release, err := q.acquireTx()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if tx == nil {
+		return q.db.PrepareContext(ctx, query)
+	}
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if q.scope != nil {
+		q.scope.addStmt(stmt)
+	}
+	return stmt, nil
+}
+
+func (q *querier) Stmt(stmt *sql.Stmt) *sql.Stmt {
+	return q.StmtContext(context.Background(), stmt)
+}
+
+func (q *querier) StmtContext(ctx context.Context, stmt *sql.Stmt) *sql.Stmt {
+	if q == nil || !q.InTransaction() || stmt == nil {
+		return stmt
+	}
+	tx, release, err := q.acquireTx()
+	if err != nil {
+		return errsql.Stmt(err)
+	}
+	defer release()
+	if q.scope == nil {
+		return tx.StmtContext(ctx, stmt)
+	}
+	return q.scope.root().txStmt(stmt, func(stmt *sql.Stmt) *sql.Stmt { return tx.StmtContext(ctx, stmt) })
+}
+
+func (q *querier) Atomic(f func(context.Context, Querier) error) *Error {
+	return q.AtomicWithOptions(AtomicOptions{}, f)
+}
+
+func (q *querier) AtomicNamed(label string, f func(context.Context, Querier) error) *Error {
+	return q.AtomicWithOptions(AtomicOptions{Label: label}, f)
 }
 
 // using named returns so the deferred function call can modify the returned error
-func (q *querier) Atomic(f func(context.Context, Querier) error) (err *Error) {
+func (q *querier) AtomicWithOptions(opts AtomicOptions, f func(context.Context, Querier) error) (err *Error) {
 	// q should never be modified, instead a nextQ should be created and used
 
 	if q == nil {
@@ -127,86 +351,430 @@ func (q *querier) Atomic(f func(context.Context, Querier) error) (err *Error) {
 	if q.savepointer == nil {
 		return newError(nil, ErrInvalidQuerier)
 	}
+	// With WithConcurrentUse(), q stays locked until the nested savepoint ends so that the savepoint's statements
+	// can't be interleaved with other statements run with q
+	release, acquireErr := func() {}, q.scope.err()
+	if opts.Propagation.usesTx() {
+		release, acquireErr = q.acquire()
+	}
+	if acquireErr != nil {
+		return newError(nil, acquireErr)
+	}
+	defer release()
+	switch {
+	case opts.Durable && q.InTransaction() && opts.Propagation != PropagationRequiresNew:
+		return newError(nil, ErrNotOutermost)
+	case opts.Propagation == PropagationMandatory && !q.InTransaction():
+		return newError(nil, ErrNoTransaction)
+	case opts.Propagation == PropagationNever && q.InTransaction():
+		return newError(nil, ErrExistingTransaction)
+	}
 	if f == nil {
 		return nil
 	}
 
 	nextQ := *q
-	if nextQ.tx == nil {
-		tx, txErr := nextQ.txCreator(nextQ.ctx, nextQ.db, nextQ.txOpts)
-		if txErr != nil {
-			return newError(nil, txErr)
+	nextQ.label = joinLabels(q.label, opts.Label)
+	if opts.Propagation == PropagationNever {
+		// Not in a transaction, so there's nothing to create or end
+		if cbErr := f(WithQuerier(nextQ.ctx, &nextQ), &nextQ); cbErr != nil {
+			err = newPhaseError(PhaseCallback, cbErr, nil)
+			err.Label = nextQ.label
+			return err
 		}
-		nextQ.tx = tx
+		return nil
+	}
+	newTx := !q.InTransaction() || opts.Propagation == PropagationRequiresNew
+	joining := !newTx && (opts.Propagation == PropagationRequired || opts.Propagation == PropagationMandatory)
+	if opts.TxOptions != nil {
+		if newTx {
+			nextQ.txOpts = *opts.TxOptions
+		} else if !txOptionsCompatible(*opts.TxOptions, nextQ.txOpts) {
+			return newError(nil, ErrTxOptionsConflict)
+		}
+	}
+	if opts.Propagation == PropagationRequiresNew {
+		nextQ.tx = nil
+		nextQ.savepointName = ""
+		nextQ.scope = newScope(nil)
 	} else {
-		nextQ.savepointName = savepointers.GenSavepointName()
-		if _, execErr := nextQ.tx.ExecContext(nextQ.ctx,
-			nextQ.savepointer.Create(nextQ.savepointName)); execErr != nil {
-			return newError(nil, execErr)
+		nextQ.scope = newScope(q.scope)
+		nextQ.scope.joined = joining
+	}
+	defer func() {
+		if err != nil {
+			err.Depth = nextQ.Depth()
+			err.Label = nextQ.label
+		}
+	}()
+	hooks := nextQ.getHooks()
+	started := time.Now()
+	switch {
+	case newTx:
+		nextQ.ctx = hooks.BeforeBegin(nextQ.ctx, nextQ.hookInfo("BEGIN"))
+		nextQ.scope.start = func() (*sql.Tx, error) {
+			begin := time.Now()
+			tx, txErr := nextQ.txCreator(WithQuerier(nextQ.ctx, &nextQ), nextQ.db, nextQ.txOpts)
+			if txErr == nil && nextQ.commitVerifier != nil {
+				tx, txErr = nextQ.recordTxID(tx)
+			}
+			info := nextQ.hookInfo("BEGIN")
+			info.Duration, info.Err = time.Since(begin), txErr
+			hooks.AfterBegin(nextQ.ctx, info)
+			return tx, txErr
+		}
+	case joining:
+		nextQ.scope.start = q.ensureTx
+	default:
+		nextQ.savepointName = nextQ.genSavepointName()
+		createStmt := nextQ.savepointer.Create(nextQ.savepointName)
+		nextQ.ctx = hooks.BeforeSavepoint(nextQ.ctx, nextQ.hookInfo(createStmt))
+		nextQ.scope.start = func() (*sql.Tx, error) {
+			tx, txErr := q.ensureTx()
+			if txErr != nil {
+				return nil, txErr
+			}
+			create := time.Now()
+			_, execErr := tx.ExecContext(nextQ.ctx, nextQ.comment(nextQ.ctx, createStmt))
+			info := nextQ.hookInfo(createStmt)
+			info.Duration, info.Err = time.Since(create), execErr
+			hooks.AfterSavepoint(nextQ.ctx, info)
+			if execErr != nil {
+				return nil, execErr
+			}
+			return tx, nil
 		}
 	}
 
+	// q can't be used until the nested transaction or savepoint ends. With WithConcurrentUse(), q is locked instead.
+	// A new transaction doesn't use q's transaction, so q can still be used.
+	if !q.concurrent && opts.Propagation.usesTx() {
+		q.scope.setActive(true)
+	}
+
 	/***************************************************************
 	* After this comment/deferred call, named returns must be used *
 	***************************************************************/
 	defer func() {
-		// TODO: don't do anything if we're dealing with an empty orig error
-		if r := recover(); err != nil || r != nil {
-			if r != nil {
+		nextQ.scope.close()
+		defer q.scope.setActive(false)
+		nextQ.scope.closeStmts()
+
+		if nextQ.scope.joined {
+			// Nothing is rolled back or released when joining a transaction, so the callbacks are left to the
+			// enclosing savepoint or transaction. Any panic is left to propagate.
+			q.scope.promote(nextQ.scope)
+			if err != nil {
+				// The enclosing savepoint or transaction is rolled back instead since there's no savepoint
+				nextQ.scope.savepoint().rollbackOnly.Store(true)
+				if errors.Is(err.Err, ErrRollback) {
+					// The rollback was intentional
+					err = nil
+				}
+			}
+			return
+		}
+		// nil if the transaction wasn't begun or the savepoint wasn't created. See WithLazyBegin()
+		tx := nextQ.scope.currentTx()
+
+		if r := recover(); r != nil {
+			// err is always set so that a rollback error can be reported along with the panic
+			err = newPhaseError(PhaseCallback, &PanicError{Value: r, Stack: debug.Stack()}, nil)
+			if !nextQ.recoverPanics {
 				// re-throw panic
 				defer func() {
 					panic(r)
 				}()
 			}
-
+		} else if err == nil && nextQ.scope.rollbackOnly.Load() {
+			// Rolled back instead of being released or committed
+			phase := PhaseCommit
 			if nextQ.usingSavepoint() {
+				phase = PhaseRelease
+			}
+			err = newPhaseError(phase, nil, ErrRollbackOnly)
+		} else if err == nil && !nextQ.usingSavepoint() {
+			info := nextQ.hookInfo(txStmt(tx, "COMMIT"))
+			if hookErr := hooks.BeforeCommit(nextQ.ctx, info); hookErr != nil {
+				// Rolled back instead of being committed
+				err = newPhaseError(PhaseCommit, nil, hookErr)
+			}
+		}
+
+		// TODO: don't do anything if we're dealing with an empty orig error
+		if err != nil {
+			// The rollback callbacks are called regardless of whether or not the rollback succeeds
+			rbCause := err.Err
+			if rbCause == nil {
+				rbCause = err.Atomic
+			}
+			defer nextQ.scope.callbacks.rollback(nextQ.ctx, rbCause)
+
+			info := nextQ.hookInfo("")
+			switch {
+			case tx == nil:
+				// No statement is run. See below
+			case nextQ.usingSavepoint():
+				info.Statement = nextQ.savepointer.Rollback(nextQ.savepointName)
+			default:
+				info.Statement = "ROLLBACK"
+			}
+			info.Cause, info.CausePhase = rbCause, err.Phase
+			hooks.BeforeRollback(nextQ.ctx, info)
+			rollback := time.Now()
+			switch {
+			case tx == nil:
+				// The transaction wasn't begun or the savepoint wasn't created, so there's nothing to roll back
+			case nextQ.usingSavepoint():
 				// Rollback savepoint on error
-				if _, execErr := nextQ.tx.ExecContext(nextQ.ctx,
-					nextQ.savepointer.Rollback(nextQ.savepointName)); execErr != nil {
-					err.Atomic = execErr
-					return
-				}
-			} else {
+				_, info.Err = tx.ExecContext(nextQ.ctx, nextQ.comment(nextQ.ctx, info.Statement))
+			default:
 				// Rollback transaction on error
-				if rbErr := nextQ.tx.Rollback(); rbErr != nil {
-					err.Atomic = rbErr
-					return
-				}
+				info.Err = tx.Rollback()
+			}
+			info.Duration, info.Elapsed = time.Since(rollback), time.Since(started)
+			hooks.AfterRollback(nextQ.ctx, info)
+			if info.Err != nil {
+				err.Atomic = joinErrs(err.Atomic, info.Err)
+				err.Phase = PhaseRollback
+				return
+			}
+			if errors.Is(err.Err, ErrRollback) {
+				// The rollback was intentional
+				err = nil
 			}
 		} else {
 			if nextQ.usingSavepoint() {
-				// Release savepoint on success
-				releaseStmt := nextQ.savepointer.Release(nextQ.savepointName)
-				if releaseStmt == "" {
-					// Some SQL RDBMSs don't support releasing savepoints
-					return
+				// Release savepoint on success. The callbacks are promoted to the parent savepoint or transaction
+				// and are only called once the transaction is committed or rolled back.
+				q.scope.promote(nextQ.scope)
+				// The savepoint may not have been created or the SQL RDBMS may not support releasing savepoints
+				info := nextQ.hookInfo(txStmt(tx, nextQ.savepointer.Release(nextQ.savepointName)))
+				release := time.Now()
+				if info.Statement != "" {
+					_, info.Err = tx.ExecContext(nextQ.ctx, nextQ.comment(nextQ.ctx, info.Statement))
 				}
-				if _, execErr := nextQ.tx.ExecContext(nextQ.ctx, releaseStmt); execErr != nil {
-					err = newError(nil, execErr)
+				info.Duration, info.Elapsed = time.Since(release), time.Since(started)
+				hooks.OnRelease(nextQ.ctx, info)
+				if info.Err != nil {
+					err = newPhaseError(PhaseRelease, nil, info.Err)
 					return
 				}
 			} else {
 				// Commit transaction on success
-				if commitErr := nextQ.tx.Commit(); commitErr != nil {
-					err = newError(nil, commitErr)
+				info := nextQ.hookInfo(txStmt(tx, "COMMIT"))
+				commit := time.Now()
+				if tx != nil {
+					// The transaction may not have been begun, so there may be nothing to commit
+					if commitErr := tx.Commit(); commitErr != nil {
+						info.Err = nextQ.commitOutcome(commitErr)
+					}
+				}
+				info.Duration, info.Elapsed = time.Since(commit), time.Since(started)
+				hooks.AfterCommit(nextQ.ctx, info)
+				if info.Err != nil {
+					err = newPhaseError(PhaseCommit, nil, info.Err)
+					if errors.Is(info.Err, ErrCommitOutcomeUnknown) {
+						// The transaction may have been committed, so it can't be treated as rolled back
+						nextQ.scope.callbacks.unknown(nextQ.ctx, info.Err)
+						return
+					}
+					nextQ.scope.callbacks.rollback(nextQ.ctx, info.Err)
 					return
 				}
+				// The connection may have been lost but the transaction was committed. See WithCommitVerifier()
+				nextQ.scope.callbacks.commit(nextQ.ctx)
 			}
 		}
 	}()
 
-	cbErr := f(nextQ.ctx, &nextQ)
+	// With WithLazyBegin(), the transaction is begun and the savepoint is created by the first statement instead
+	if !q.lazy {
+		tx, txErr := nextQ.scope.ensureTx()
+		if txErr != nil {
+			if newTx {
+				return newPhaseError(PhaseBegin, nil, txErr)
+			}
+			return newPhaseError(PhaseSavepointCreate, nil, txErr)
+		}
+		nextQ.tx = tx
+	}
+
+	cbErr := f(WithQuerier(nextQ.ctx, &nextQ), &nextQ)
 	if cbErr != nil {
-		err = newError(cbErr, nil)
+		err = newPhaseError(PhaseCallback, cbErr, nil)
 	}
 
 	return // nolint:nakedret
 }
 
+func (q *querier) Err() error {
+	if q == nil {
+		return ErrNilQuerier
+	}
+	if q.db == nil {
+		return ErrInvalidQuerier
+	}
+	return q.scope.err()
+}
+
+func (q *querier) InTransaction() bool {
+	return q != nil && (q.tx != nil || q.scope != nil)
+}
+
+func (q *querier) Depth() int {
+	if q == nil {
+		return 0
+	}
+	depth := 0
+	for s := q.scope; s != nil; s = s.parent {
+		if !s.joined {
+			depth++
+		}
+	}
+	return depth
+}
+
+func (q *querier) SavepointName() string {
+	if q == nil {
+		return ""
+	}
+	return q.savepointName
+}
+
+func (q *querier) Label() string {
+	if q == nil {
+		return ""
+	}
+	return q.label
+}
+
+func (q *querier) TxOptions() sql.TxOptions {
+	if q == nil {
+		return sql.TxOptions{}
+	}
+	return q.txOpts
+}
+
+func (q *querier) Tx() *sql.Tx {
+	if q == nil {
+		return nil
+	}
+	tx, err := q.ensureTx()
+	if err != nil {
+		return nil
+	}
+	return tx
+}
+
+// ensureTx returns q's transaction or nil if q isn't in a transaction. With WithLazyBegin(), the transaction is begun
+// and q's savepoints are created if they haven't been already.
+func (q *querier) ensureTx() (*sql.Tx, error) {
+	if q.tx != nil || q.scope == nil {
+		return q.tx, nil
+	}
+	return q.scope.ensureTx()
+}
+
+// acquire checks that q can be used to run a statement. With WithConcurrentUse(), q's transaction or savepoint is
+// also locked until the returned release function is called.
+func (q *querier) acquire() (release func(), err error) {
+	if err := q.scope.err(); err != nil {
+		return nil, err
+	}
+	if !q.concurrent || q.scope == nil {
+		return func() {}, nil
+	}
+	q.scope.mu.Lock()
+	// The transaction or savepoint may have ended while waiting for the lock
+	if err := q.scope.err(); err != nil {
+		q.scope.mu.Unlock()
+		return nil, err
+	}
+	return q.scope.mu.Unlock, nil
+}
+
+// acquireTx is like acquire() but also returns the transaction that the statement should be run in or nil if q isn't
+// in a transaction
+func (q *querier) acquireTx() (*sql.Tx, func(), error) {
+	release, err := q.acquire()
+	if err != nil {
+		return nil, nil, err
+	}
+	tx, err := q.ensureTx()
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	return tx, release, nil
+}
+
+// AcquireTx prepares the Querier for running a statement outside of the Querier's methods and returns the
+// transaction that the statement should be run in, or nil if the Querier isn't in a transaction.
+// The returned release function must be called once the statement has been run.
+// It's intended for packages that extend a Querier, like satomicx.
+func AcquireTx(q Querier) (tx *sql.Tx, release func(), err error) {
+	if q == nil {
+		return nil, nil, ErrNilQuerier
+	}
+	_q, ok := q.(*querier)
+	if !ok {
+		if err := q.Err(); err != nil {
+			return nil, nil, err
+		}
+		return q.Tx(), func() {}, nil
+	}
+	if _q == nil {
+		return nil, nil, ErrNilQuerier
+	}
+	if _q.db == nil {
+		return nil, nil, ErrInvalidQuerier
+	}
+	return _q.acquireTx()
+}
+
+// BufferRows returns whether the rows of a statement run in the transaction returned by AcquireTx() must be read before
+// the release function is called. With WithConcurrentUse(), other goroutines can run statements in the transaction
+// once it's released, which drivers don't support while rows are still being read.
+func BufferRows(q Querier) bool {
+	_q, ok := q.(*querier)
+	return ok && _q != nil && _q.concurrent && _q.InTransaction()
+}
+
+// txStmt returns the statement if the transaction was begun or the savepoint was created. Otherwise, "" is returned
+// since there's nothing to run the statement on. See WithLazyBegin()
+func txStmt(tx *sql.Tx, stmt string) string {
+	if tx == nil {
+		return ""
+	}
+	return stmt
+}
+
 // usingSavepoint determines whether or not the querier is using a savepoint or transaction
 func (q *querier) usingSavepoint() bool { return q.savepointName != "" }
 
-// TxCreator is used to create transactions for a Querier
+// genSavepointName generates a unique name for the querier's savepoint. With WithLabeledSavepointNames(), the name is
+// derived from the querier's label.
+func (q *querier) genSavepointName() string {
+	if q.labelNames && q.label != "" {
+		return savepointers.GenLabeledSavepointName(q.label)
+	}
+	return savepointers.GenSavepointName()
+}
+
+// joinLabels appends the label to the parent's label path. An empty label inherits the parent's label path.
+func joinLabels(parent, label string) string {
+	switch {
+	case label == "":
+		return parent
+	case parent == "":
+		return label
+	}
+	return parent + LabelSeparator + label
+}
+
+// TxCreator is used to create transactions for a Querier. The context carries the Querier that the transaction is
+// created for. See QuerierFromContext().
 type TxCreator func(context.Context, *sql.DB, sql.TxOptions) (*sql.Tx, error)
 
 // DefaultTxCreator is the default TxCreator to be used
@@ -216,13 +784,13 @@ func DefaultTxCreator(ctx context.Context, db *sql.DB, txOpts sql.TxOptions) (*s
 
 // NewQuerier creates a new Querier
 func NewQuerier(ctx context.Context, db *sql.DB, savepointer savepointers.Savepointer,
-	txOpts sql.TxOptions) (Querier, error) {
-	return NewQuerierWithTxCreator(ctx, db, savepointer, txOpts, DefaultTxCreator)
+	txOpts sql.TxOptions, opts ...Option) (Querier, error) {
+	return NewQuerierWithTxCreator(ctx, db, savepointer, txOpts, DefaultTxCreator, opts...)
 }
 
 // NewQuerierWithTxCreator creates a new Querier, allowing the transaction creation to be customized
 func NewQuerierWithTxCreator(ctx context.Context, db *sql.DB, savepointer savepointers.Savepointer,
-	txOpts sql.TxOptions, txCreator TxCreator) (Querier, error) {
+	txOpts sql.TxOptions, txCreator TxCreator, opts ...Option) (Querier, error) {
 	if db == nil {
 		return nil, ErrNeedsDb
 	}
@@ -235,6 +803,12 @@ func NewQuerierWithTxCreator(ctx context.Context, db *sql.DB, savepointer savepo
 	if txCreator == nil {
 		txCreator = DefaultTxCreator
 	}
-	return &querier{ctx: ctx, db: db, txCreator: txCreator, txOpts: txOpts, tx: nil, savepointer: savepointer,
-		savepointName: ""}, nil
+	q := &querier{ctx: ctx, db: db, txCreator: txCreator, txOpts: txOpts, tx: nil, savepointer: savepointer,
+		savepointName: ""}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(q)
+		}
+	}
+	return q, nil
 }
@@ -4,7 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"io"
+	"math"
 	"testing"
+	"time"
 )
 
 import (
@@ -21,7 +23,7 @@ func errsEq(a, b *Error) bool {
 		return true
 	}
 	if a != nil && b != nil {
-		return *a == *b
+		return a.Err == b.Err && a.Atomic == b.Atomic
 	}
 	return false
 }
@@ -173,23 +175,23 @@ func TestQuerierQueryRow(t *testing.T) {
 	querierWithTx.tx = tx
 
 	testCases := []struct {
-		name         string
-		q            *querier
-		expectNilRow bool
-		_sqlmock     sqlmock.Sqlmock
+		name        string
+		q           *querier
+		expectedErr error
+		_sqlmock    sqlmock.Sqlmock
 	}{
-		{name: "nil querier", q: nilQuerier, expectNilRow: true},
-		{name: "nil db", q: querierNilDb, expectNilRow: true, _sqlmock: nilDbSqlmock},
-		{name: "nil tx", q: querierNilTx, expectNilRow: false, _sqlmock: nilTxSqlmock},
-		{name: "with tx", q: querierWithTx, expectNilRow: false, _sqlmock: withTxSqlmock},
+		{name: "nil querier", q: nilQuerier, expectedErr: ErrNilQuerier},
+		{name: "nil db", q: querierNilDb, expectedErr: ErrInvalidQuerier, _sqlmock: nilDbSqlmock},
+		{name: "nil tx", q: querierNilTx, _sqlmock: nilTxSqlmock},
+		{name: "with tx", q: querierWithTx, _sqlmock: withTxSqlmock},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			if row := tc.q.QueryRow(""); row != nil && tc.expectNilRow {
-				t.Errorf("Expected a nil row but got: %+v", row)
-			} else if row == nil && !tc.expectNilRow {
+			if row := tc.q.QueryRow(""); row == nil {
 				t.Error("Got an unxpected nil row")
+			} else if err := row.Err(); err != tc.expectedErr {
+				t.Errorf("Didn't get the expected error: %+v != %+v", err, tc.expectedErr)
 			}
 
 			if tc._sqlmock != nil {
@@ -235,3 +237,100 @@ func TestQuerierAtomicErrs(t *testing.T) {
 		})
 	}
 }
+
+func TestQuerierPrepare(t *testing.T) {
+	ctx := context.Background()
+	var nilQuerier *querier
+
+	querierNilDb, nilDbSqlmock := genQuerier(t, nil)
+	querierNilDb.db = nil
+
+	querierNilTx, nilTxSqlmock := genQuerier(t, func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+		m.ExpectPrepare("")
+		return m
+	})
+
+	querierWithTx, withTxSqlmock := genQuerier(t, func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+		m.ExpectBegin()
+		m.ExpectPrepare("")
+		return m
+	})
+	tx, err := querierWithTx.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		t.Fatal("Could not start transaction:", err)
+	}
+	querierWithTx.tx = tx
+
+	testCases := []struct {
+		name        string
+		q           *querier
+		expectedErr error
+		_sqlmock    sqlmock.Sqlmock
+	}{
+		{name: "nil querier", q: nilQuerier, expectedErr: ErrNilQuerier},
+		{name: "nil db", q: querierNilDb, expectedErr: ErrInvalidQuerier, _sqlmock: nilDbSqlmock},
+		{name: "nil tx", q: querierNilTx, expectedErr: nil, _sqlmock: nilTxSqlmock},
+		{name: "with tx", q: querierWithTx, expectedErr: nil, _sqlmock: withTxSqlmock},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := tc.q.Prepare(""); err != tc.expectedErr {
+				t.Errorf("Didn't get the expected error: %+v != %+v", err, tc.expectedErr)
+			}
+
+			if tc._sqlmock != nil {
+				if err := tc._sqlmock.ExpectationsWereMet(); err != nil {
+					t.Error(err)
+				}
+			}
+		})
+	}
+}
+
+func TestIsSatomicFunc(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected bool
+	}{
+		{name: "github.com/dhui/satomic.(*querier).AtomicWithOptions.func2", expected: true},
+		{name: "github.com/dhui/satomic/satomicx.(*wrappedQuerier).Atomicx", expected: true},
+		{name: "github.com/dhui/satomic_test.TestQuerierLogger", expected: false},
+		{name: "github.com/dhui/satomic/satomicx_test.TestQuerierHooks.func1", expected: false},
+		{name: "github.com/dhui/satomicity.Atomic", expected: false},
+		{name: "runtime.gopanic", expected: true},
+		{name: "main.main", expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if isSatomic := isSatomicFunc(tc.name); isSatomic != tc.expected {
+				t.Errorf("Didn't get the expected result: %v != %v", isSatomic, tc.expected)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	testCases := []struct {
+		name     string
+		policy   RetryPolicy
+		retry    int
+		expected time.Duration
+	}{
+		{name: "first retry", policy: RetryPolicy{Backoff: time.Millisecond}, retry: 1, expected: time.Millisecond},
+		{name: "doubled", policy: RetryPolicy{Backoff: time.Millisecond}, retry: 3, expected: 4 * time.Millisecond},
+		{name: "max backoff", policy: RetryPolicy{Backoff: time.Millisecond, MaxBackoff: 3 * time.Millisecond},
+			retry: 3, expected: 3 * time.Millisecond},
+		{name: "overflow", policy: RetryPolicy{Backoff: time.Millisecond}, retry: 100,
+			expected: time.Duration(math.MaxInt64)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if d := tc.policy.delay(tc.retry); d != tc.expected {
+				t.Errorf("Didn't get the expected delay: %v != %v", d, tc.expected)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"io"
+	"strings"
 	"testing"
 )
 
@@ -346,3 +347,382 @@ func TestQuerierBaseImplementers(t *testing.T) { //nolint:revive
 	// Test that sql.Tx implements the satomic.QuerierBase interface
 	f(&sql.Tx{})
 }
+
+func TestQuerierStmt(t *testing.T) {
+	db, _sqlmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal("Error creating sqlmock:", err)
+	}
+	defer db.Close() // nolint:errcheck
+
+	_sqlmock.ExpectPrepare("SELECT 1;")
+	_sqlmock.ExpectBegin()
+	_sqlmock.ExpectQuery("SELECT 1;").WillReturnRows(sqlmock.NewRows([]string{""}).AddRow(1))
+	_sqlmock.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectQuery("SELECT 1;").WillReturnRows(sqlmock.NewRows([]string{""}).AddRow(1))
+	_sqlmock.ExpectPrepare("SELECT 2;").WillBeClosed()
+	_sqlmock.ExpectQuery("SELECT 2;").WillReturnRows(sqlmock.NewRows([]string{""}).AddRow(2))
+	_sqlmock.ExpectExec("RELEASE 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectCommit()
+
+	ctx := context.Background()
+	q, err := satomic.NewQuerier(ctx, db, mock.NewSavepointer(io.Discard, true), sql.TxOptions{})
+	if err != nil {
+		t.Fatal("Error creating Querier:", err)
+	}
+
+	dbStmt, err := q.PrepareContext(ctx, "SELECT 1;")
+	if err != nil {
+		t.Fatal("Error preparing statement:", err)
+	}
+	if stmt := q.Stmt(dbStmt); stmt != dbStmt {
+		t.Error("Stmt() outside of a transaction should return the given statement")
+	}
+
+	var savepointStmt *sql.Stmt
+	if err := q.Atomic(func(ctx context.Context, q satomic.Querier) error {
+		var dummy int
+		txStmt := q.StmtContext(ctx, dbStmt)
+		if txStmt == dbStmt {
+			t.Error("Stmt() within a transaction should return a transaction-specific statement")
+		}
+		if err := txStmt.QueryRowContext(ctx).Scan(&dummy); err != nil {
+			return err
+		}
+		if err := q.Atomic(func(ctx context.Context, q satomic.Querier) error {
+			if stmt := q.StmtContext(ctx, dbStmt); stmt != txStmt {
+				t.Error("Stmt() within a savepoint should return the transaction's statement")
+			}
+			if err := q.StmtContext(ctx, dbStmt).QueryRowContext(ctx).Scan(&dummy); err != nil {
+				return err
+			}
+			stmt, err := q.PrepareContext(ctx, "SELECT 2;")
+			if err != nil {
+				return err
+			}
+			savepointStmt = stmt
+			return stmt.QueryRowContext(ctx).Scan(&dummy)
+		}); err != nil {
+			return err
+		}
+		if _, err := savepointStmt.QueryContext(ctx); err == nil {
+			t.Error("Statement prepared within a savepoint wasn't closed when the savepoint ended")
+		}
+		return nil
+	}); err != nil {
+		t.Error(err)
+	}
+
+	if err := _sqlmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQuerierClosed(t *testing.T) {
+	db, _sqlmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal("Error creating sqlmock:", err)
+	}
+	defer db.Close() // nolint:errcheck
+
+	_sqlmock.ExpectPrepare("SELECT 1;")
+	_sqlmock.ExpectBegin()
+	_sqlmock.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectExec("RELEASE 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectCommit()
+
+	ctx := context.Background()
+	stmt, err := db.PrepareContext(ctx, "SELECT 1;")
+	if err != nil {
+		t.Fatal("Error preparing statement:", err)
+	}
+	q, err := satomic.NewQuerier(ctx, db, mock.NewSavepointer(io.Discard, true), sql.TxOptions{})
+	if err != nil {
+		t.Fatal("Error creating Querier:", err)
+	}
+
+	checkErr := func(q satomic.Querier, expectedErr error) {
+		t.Helper()
+		if err := q.Err(); err != expectedErr {
+			t.Errorf("Didn't get the expected Err(): %+v != %+v", err, expectedErr)
+		}
+		if _, err := q.ExecContext(ctx, "UPDATE 1;"); err != expectedErr {
+			t.Errorf("Didn't get the expected ExecContext() error: %+v != %+v", err, expectedErr)
+		}
+		if _, err := q.QueryContext(ctx, "SELECT 1;"); err != expectedErr {
+			t.Errorf("Didn't get the expected QueryContext() error: %+v != %+v", err, expectedErr)
+		}
+		if err := q.QueryRowContext(ctx, "SELECT 1;").Scan(new(int)); err != expectedErr {
+			t.Errorf("Didn't get the expected QueryRowContext() error: %+v != %+v", err, expectedErr)
+		}
+		if _, err := q.PrepareContext(ctx, "SELECT 1;"); err != expectedErr {
+			t.Errorf("Didn't get the expected PrepareContext() error: %+v != %+v", err, expectedErr)
+		}
+		if _, err := q.StmtContext(ctx, stmt).QueryContext(ctx); err != expectedErr {
+			t.Errorf("Didn't get the expected StmtContext() error: %+v != %+v", err, expectedErr)
+		}
+		expectedAtomicErr := satomictest.NewError(nil, expectedErr)
+		if err := q.Atomic(func(context.Context, satomic.Querier) error {
+			t.Error("Callback shouldn't be called")
+			return nil
+		}); !satomictest.ErrsEq(err, expectedAtomicErr) {
+			t.Errorf("Didn't get the expected Atomic() error: %+v != %+v", err, expectedAtomicErr)
+		}
+	}
+
+	var txQ, savepointQ satomic.Querier
+	if err := q.Atomic(func(ctx context.Context, q satomic.Querier) error {
+		txQ = q
+		if err := q.Atomic(func(ctx context.Context, q satomic.Querier) error {
+			savepointQ = q
+			checkErr(txQ, satomic.ErrNestedQuerierActive)
+			return nil
+		}); err != nil {
+			return err
+		}
+		checkErr(savepointQ, satomic.ErrQuerierClosed)
+		return nil
+	}); err != nil {
+		t.Error(err)
+	}
+	checkErr(txQ, satomic.ErrQuerierClosed)
+	checkErr(savepointQ, satomic.ErrQuerierClosed)
+
+	if err := _sqlmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQuerierIntrospection(t *testing.T) {
+	db, _sqlmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal("Error creating sqlmock:", err)
+	}
+	defer db.Close() // nolint:errcheck
+
+	_sqlmock.ExpectBegin()
+	_sqlmock.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectExec("RELEASE 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectCommit()
+
+	ctx := context.Background()
+	txOpts := sql.TxOptions{Isolation: sql.LevelSerializable}
+	q, err := satomic.NewQuerier(ctx, db, mock.NewSavepointer(io.Discard, true), sql.TxOptions{})
+	if err != nil {
+		t.Fatal("Error creating Querier:", err)
+	}
+
+	check := func(q satomic.Querier, inTx bool, depth int, inSavepoint bool, expectedTxOpts sql.TxOptions) {
+		t.Helper()
+		if q.InTransaction() != inTx {
+			t.Errorf("Didn't get the expected InTransaction(): %v != %v", q.InTransaction(), inTx)
+		}
+		if (q.Tx() != nil) != inTx {
+			t.Errorf("Didn't get the expected Tx(): %v", q.Tx())
+		}
+		if q.Depth() != depth {
+			t.Errorf("Didn't get the expected Depth(): %d != %d", q.Depth(), depth)
+		}
+		if (q.SavepointName() != "") != inSavepoint {
+			t.Errorf("Didn't get the expected SavepointName(): %q", q.SavepointName())
+		}
+		if q.TxOptions() != expectedTxOpts {
+			t.Errorf("Didn't get the expected TxOptions(): %+v != %+v", q.TxOptions(), expectedTxOpts)
+		}
+	}
+
+	check(q, false, 0, false, sql.TxOptions{})
+	if err := q.AtomicWithOptions(satomic.AtomicOptions{TxOptions: &txOpts},
+		func(ctx context.Context, txQ satomic.Querier) error {
+			check(txQ, true, 1, false, txOpts)
+			if err := txQ.Atomic(func(ctx context.Context, savepointQ satomic.Querier) error {
+				check(savepointQ, true, 2, true, txOpts)
+				if savepointQ.Tx() != txQ.Tx() {
+					t.Error("Savepoint isn't in the transaction")
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			return nil
+		}); err != nil {
+		t.Error(err)
+	}
+
+	if err := _sqlmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQuerierAtomicErrorPhase(t *testing.T) {
+	dbErr := errors.New("db error")
+	cbErr := errors.New("callback error")
+
+	testCases := []struct {
+		name          string
+		mocker        func(sqlmock.Sqlmock) sqlmock.Sqlmock
+		savepoint     bool
+		cbErr         error
+		expectedPhase satomic.Phase
+		expectedDepth int
+	}{
+		{name: "begin", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin().WillReturnError(dbErr)
+			return m
+		}, expectedPhase: satomic.PhaseBegin, expectedDepth: 1},
+		{name: "callback", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectRollback()
+			return m
+		}, cbErr: cbErr, expectedPhase: satomic.PhaseCallback, expectedDepth: 1},
+		{name: "rollback", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectRollback().WillReturnError(dbErr)
+			return m
+		}, cbErr: cbErr, expectedPhase: satomic.PhaseRollback, expectedDepth: 1},
+		{name: "commit", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectCommit().WillReturnError(dbErr)
+			return m
+		}, expectedPhase: satomic.PhaseCommit, expectedDepth: 1},
+		{name: "savepoint create", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("SAVEPOINT 1;").WillReturnError(dbErr)
+			m.ExpectCommit()
+			return m
+		}, savepoint: true, expectedPhase: satomic.PhaseSavepointCreate, expectedDepth: 2},
+		{name: "savepoint callback", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectExec("ROLLBACK TO 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectCommit()
+			return m
+		}, savepoint: true, cbErr: cbErr, expectedPhase: satomic.PhaseCallback, expectedDepth: 2},
+		{name: "savepoint rollback", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectExec("ROLLBACK TO 1;").WillReturnError(dbErr)
+			m.ExpectCommit()
+			return m
+		}, savepoint: true, cbErr: cbErr, expectedPhase: satomic.PhaseRollback, expectedDepth: 2},
+		{name: "savepoint release", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectExec("RELEASE 1;").WillReturnError(dbErr)
+			m.ExpectCommit()
+			return m
+		}, savepoint: true, expectedPhase: satomic.PhaseRelease, expectedDepth: 2},
+	}
+
+	ctx := context.Background()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, _sqlmock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal("Error creating sqlmock:", err)
+			}
+			defer db.Close() // nolint:errcheck
+
+			_sqlmock = tc.mocker(_sqlmock)
+
+			q, err := satomic.NewQuerier(ctx, db, mock.NewSavepointer(io.Discard, true), sql.TxOptions{})
+			if err != nil {
+				t.Fatal("Error creating Querier:", err)
+			}
+
+			f := func(context.Context, satomic.Querier) error { return tc.cbErr }
+			var atomicErr *satomic.Error
+			if tc.savepoint {
+				if err := q.Atomic(func(ctx context.Context, q satomic.Querier) error {
+					atomicErr = q.Atomic(f)
+					return nil
+				}); err != nil {
+					t.Error(err)
+				}
+			} else {
+				atomicErr = q.Atomic(f)
+			}
+			if atomicErr == nil {
+				t.Fatal("Expected an error")
+			}
+			if atomicErr.Phase != tc.expectedPhase {
+				t.Errorf("Didn't get the expected phase: %v != %v", atomicErr.Phase, tc.expectedPhase)
+			}
+			if atomicErr.Depth != tc.expectedDepth {
+				t.Errorf("Didn't get the expected depth: %d != %d", atomicErr.Depth, tc.expectedDepth)
+			}
+
+			if err := _sqlmock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestQuerierLabel(t *testing.T) {
+	db, _sqlmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal("Error creating sqlmock:", err)
+	}
+	defer db.Close() // nolint:errcheck
+
+	_sqlmock.ExpectBegin()
+	_sqlmock.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectExec("SAVEPOINT 2;").WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectExec("ROLLBACK TO 2;").WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectExec("SAVEPOINT 3;").WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectExec("RELEASE 3;").WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectExec("RELEASE 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectCommit()
+
+	ctx := context.Background()
+	cbErr := errors.New("callback error")
+	q, err := satomic.NewQuerier(ctx, db, mock.NewSavepointer(io.Discard, true), sql.TxOptions{},
+		satomic.WithLabeledSavepointNames())
+	if err != nil {
+		t.Fatal("Error creating Querier:", err)
+	}
+
+	check := func(q satomic.Querier, expectedLabel, expectedSavepointPrefix string) {
+		t.Helper()
+		if q.Label() != expectedLabel {
+			t.Errorf("Didn't get the expected Label(): %q != %q", q.Label(), expectedLabel)
+		}
+		if !strings.HasPrefix(q.SavepointName(), expectedSavepointPrefix) {
+			t.Errorf("Didn't get the expected SavepointName(): %q doesn't start with %q", q.SavepointName(),
+				expectedSavepointPrefix)
+		}
+	}
+
+	check(q, "", "")
+	if err := q.AtomicNamed("checkout", func(ctx context.Context, q satomic.Querier) error {
+		check(q, "checkout", "")
+		if err := q.AtomicNamed("reserve-stock", func(ctx context.Context, q satomic.Querier) error {
+			check(q, "checkout/reserve-stock", "checkout_reserve_stock_")
+			expectedErr := &satomic.Error{Err: cbErr, Phase: satomic.PhaseCallback, Depth: 3,
+				Label: "checkout/reserve-stock/decrement"}
+			if err := q.AtomicNamed("decrement", func(ctx context.Context, q satomic.Querier) error {
+				check(q, "checkout/reserve-stock/decrement", "reserve_stock_decrement_")
+				return cbErr
+			}); err == nil || *err != *expectedErr {
+				t.Errorf("Didn't get the expected error: %+v != %+v", err, expectedErr)
+			}
+			if err := q.Atomic(func(ctx context.Context, q satomic.Querier) error {
+				check(q, "checkout/reserve-stock", "checkout_reserve_stock_")
+				return nil
+			}); err != nil {
+				return err
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		return nil
+	}); err != nil {
+		t.Error(err)
+	}
+
+	if err := _sqlmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
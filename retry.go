@@ -0,0 +1,128 @@
+package satomic
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+import (
+	"github.com/dhui/satomic/savepointers"
+)
+
+var (
+	// ErrNestedRetry is the canonical error value when AtomicWithRetry() is called from a Querier that's already in
+	// a transaction. Only the outermost transaction can be retried.
+	ErrNestedRetry = errors.New("Can't retry Atomic within a transaction")
+	// ErrNoErrorClassifier is the canonical error value when AtomicWithRetry() is called without a
+	// RetryPolicy.Retryable function from a Querier without an ErrorClassifier, since no error would be retried.
+	// See WithErrorClassifier()
+	ErrNoErrorClassifier = errors.New("Can't determine which errors to retry without an ErrorClassifier")
+)
+
+// RetryPolicy determines how AtomicWithRetry() retries a transaction
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times the transaction is attempted, including the first attempt.
+	// Values less than 1 are treated as 1.
+	MaxAttempts int
+	// Backoff is the delay before the first retry. The delay is doubled for every subsequent retry.
+	Backoff time.Duration
+	// MaxBackoff caps the delay between retries. A zero value doesn't cap the delay.
+	MaxBackoff time.Duration
+	// Jitter is the fraction of the delay that's randomized, from 0 (no jitter) to 1 (full jitter).
+	// e.g. a Jitter of 0.2 results in delays between 80% and 100% of the computed delay.
+	Jitter float64
+	// Retryable determines whether or not the error warrants another attempt. It's called with both the callback
+	// function's error and the Atomic error, if present. If nil, IsTransient() is used with the Querier's
+	// ErrorClassifier and ErrNoErrorClassifier is returned if the Querier doesn't have one. See WithErrorClassifier()
+	Retryable func(error) bool
+	// Label labels each attempt's transaction like AtomicNamed() and is the label that retries are recorded with.
+	// See WithRecorder()
+	Label string
+}
+
+func (p RetryPolicy) retryable(err error, classifier savepointers.ErrorClassifier) bool {
+	if p.Retryable == nil {
+		return IsTransient(err, classifier)
+	}
+	return p.Retryable(err)
+}
+
+func (p RetryPolicy) maxAttempts() int {
+	if p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// shouldRetry determines whether or not the *Error returned by Atomic() should be retried
+func (p RetryPolicy) shouldRetry(err *Error, classifier savepointers.ErrorClassifier) bool {
+	if err == nil {
+		return false
+	}
+	return (err.Err != nil && p.retryable(err.Err, classifier)) ||
+		(err.Atomic != nil && p.retryable(err.Atomic, classifier))
+}
+
+// delay returns how long to wait before the given retry, where the first retry is 1
+func (p RetryPolicy) delay(retry int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < retry; i++ {
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			break
+		}
+		if d > math.MaxInt64/2 {
+			// Doubling would overflow
+			d = math.MaxInt64
+			break
+		}
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if jitter := min(max(p.Jitter, 0), 1); jitter > 0 {
+		// ignore gosec G404 - suggesting crypto/rand over math/rand/v2
+		d -= time.Duration(jitter * rand.Float64() * float64(d)) //nolint:gosec
+	}
+	return d
+}
+
+// AtomicWithRetry runs Atomic() and re-runs it in a new transaction whenever the returned error is deemed retryable
+// by the RetryPolicy. Since each attempt starts a new transaction, the callback function may be run multiple times
+// and should not have side-effects outside of the transaction.
+//
+// AtomicWithRetry can only be used from a Querier that's not already in a transaction. Otherwise, ErrNestedRetry is
+// returned since a failed transaction can't be retried from within a savepoint.
+func (q *querier) AtomicWithRetry(policy RetryPolicy, f func(context.Context, Querier) error) *Error {
+	if q == nil {
+		return newError(nil, ErrNilQuerier)
+	}
+	if q.InTransaction() {
+		return newError(nil, ErrNestedRetry)
+	}
+	if policy.Retryable == nil && q.classifier == nil {
+		return newError(nil, ErrNoErrorClassifier)
+	}
+
+	var err *Error
+	for attempt := 1; ; attempt++ {
+		err = q.AtomicNamed(policy.Label, f)
+		if attempt >= policy.maxAttempts() || !policy.shouldRetry(err, q.classifier) {
+			return err
+		}
+
+		timer := time.NewTimer(policy.delay(attempt))
+		select {
+		case <-q.ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		if q.recorder != nil {
+			q.recorder.RecordRetry(joinLabels(q.label, policy.Label))
+		}
+	}
+}
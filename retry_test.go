@@ -0,0 +1,168 @@
+package satomic_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+import (
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+import (
+	"github.com/dhui/satomic"
+	"github.com/dhui/satomic/satomictest"
+	"github.com/dhui/satomic/savepointers"
+	"github.com/dhui/satomic/savepointers/mock"
+	"github.com/dhui/satomic/savepointers/postgres/pqclassify"
+)
+
+func TestQuerierAtomicWithRetry(t *testing.T) {
+	serializationErr := errors.New("serialization error")
+	otherErr := errors.New("other error")
+	pgSerializationErr := &pq.Error{Code: "40001"}
+
+	policy := satomic.RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond,
+		Jitter: 0.5, Retryable: func(err error) bool { return errors.Is(err, serializationErr) }}
+
+	testCases := []struct {
+		name             string
+		mocker           func(sqlmock.Sqlmock) sqlmock.Sqlmock
+		policy           satomic.RetryPolicy
+		classifier       savepointers.ErrorClassifier
+		expectedAttempts int
+		expectedErr      *satomic.Error
+	}{
+		{name: "success", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("UPDATE 1;").WillReturnResult(sqlmock.NewResult(0, 1))
+			m.ExpectCommit()
+			return m
+		}, policy: policy, expectedAttempts: 1, expectedErr: nil},
+		{name: "retry callback error", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("UPDATE 1;").WillReturnError(serializationErr)
+			m.ExpectRollback()
+			m.ExpectBegin()
+			m.ExpectExec("UPDATE 1;").WillReturnResult(sqlmock.NewResult(0, 1))
+			m.ExpectCommit()
+			return m
+		}, policy: policy, expectedAttempts: 2, expectedErr: nil},
+		{name: "retry commit error", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("UPDATE 1;").WillReturnResult(sqlmock.NewResult(0, 1))
+			m.ExpectCommit().WillReturnError(serializationErr)
+			m.ExpectBegin()
+			m.ExpectExec("UPDATE 1;").WillReturnResult(sqlmock.NewResult(0, 1))
+			m.ExpectCommit()
+			return m
+		}, policy: policy, expectedAttempts: 2, expectedErr: nil},
+		{name: "max attempts", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			for i := 0; i < 3; i++ {
+				m.ExpectBegin()
+				m.ExpectExec("UPDATE 1;").WillReturnError(serializationErr)
+				m.ExpectRollback()
+			}
+			return m
+		}, policy: policy, expectedAttempts: 3, expectedErr: satomictest.NewError(serializationErr, nil)},
+		{name: "zero max attempts", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("UPDATE 1;").WillReturnError(serializationErr)
+			m.ExpectRollback()
+			return m
+		}, policy: satomic.RetryPolicy{Retryable: policy.Retryable}, expectedAttempts: 1,
+			expectedErr: satomictest.NewError(serializationErr, nil)},
+		{name: "not retryable", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("UPDATE 1;").WillReturnError(otherErr)
+			m.ExpectRollback()
+			return m
+		}, policy: policy, expectedAttempts: 1, expectedErr: satomictest.NewError(otherErr, nil)},
+		{name: "nil Retryable", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("UPDATE 1;").WillReturnError(pgSerializationErr)
+			m.ExpectRollback()
+			m.ExpectBegin()
+			m.ExpectExec("UPDATE 1;").WillReturnError(serializationErr)
+			m.ExpectRollback()
+			return m
+		}, policy: satomic.RetryPolicy{MaxAttempts: 3}, classifier: pqclassify.Classifier{}, expectedAttempts: 2,
+			expectedErr: satomictest.NewError(serializationErr, nil)},
+		{name: "nil Retryable - no classifier", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock { return m },
+			policy: satomic.RetryPolicy{MaxAttempts: 3}, classifier: nil, expectedAttempts: 0,
+			expectedErr: satomictest.NewError(nil, satomic.ErrNoErrorClassifier)},
+	}
+
+	ctx := context.Background()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, _sqlmock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal("Error creating sqlmock:", err)
+			}
+			defer db.Close() // nolint:errcheck
+
+			_sqlmock = tc.mocker(_sqlmock)
+
+			q, err := satomic.NewQuerier(ctx, db, mock.NewSavepointer(io.Discard, true), sql.TxOptions{},
+				satomic.WithErrorClassifier(tc.classifier))
+			if err != nil {
+				t.Fatal("Error creating Querier:", err)
+			}
+			attempts := 0
+			if err := q.AtomicWithRetry(tc.policy, func(ctx context.Context, q satomic.Querier) error {
+				attempts++
+				_, err := q.ExecContext(ctx, "UPDATE 1;")
+				return err
+			}); !satomictest.ErrsEq(err, tc.expectedErr) {
+				t.Errorf("Didn't get the expected error: %+v != %+v", err, tc.expectedErr)
+			}
+			if attempts != tc.expectedAttempts {
+				t.Errorf("Didn't get the expected number of attempts: %d != %d", attempts, tc.expectedAttempts)
+			}
+
+			if err := _sqlmock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestQuerierAtomicWithRetryNested(t *testing.T) {
+	db, _sqlmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal("Error creating sqlmock:", err)
+	}
+	defer db.Close() // nolint:errcheck
+
+	_sqlmock.ExpectBegin()
+	_sqlmock.ExpectCommit()
+
+	ctx := context.Background()
+	q, err := satomic.NewQuerier(ctx, db, mock.NewSavepointer(io.Discard, true), sql.TxOptions{})
+	if err != nil {
+		t.Fatal("Error creating Querier:", err)
+	}
+	expectedErr := satomictest.NewError(nil, satomic.ErrNestedRetry)
+	if err := q.Atomic(func(ctx context.Context, q satomic.Querier) error {
+		if err := q.AtomicWithRetry(satomic.RetryPolicy{MaxAttempts: 3, Retryable: func(error) bool { return true }},
+			func(context.Context, satomic.Querier) error {
+				t.Error("Callback shouldn't be called")
+				return nil
+			}); !satomictest.ErrsEq(err, expectedErr) {
+			t.Errorf("Didn't get the expected error: %+v != %+v", err, expectedErr)
+		}
+		return nil
+	}); err != nil {
+		t.Error(err)
+	}
+
+	if err := _sqlmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
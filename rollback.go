@@ -0,0 +1,38 @@
+package satomic
+
+import (
+	"errors"
+)
+
+var (
+	// ErrRollback can be returned by an Atomic() callback function to intentionally roll back the transaction or
+	// savepoint. e.g. for a dry run. The error may be wrapped. Atomic() returns nil unless the rollback fails, in
+	// which case the callback function's error is returned along with the rollback error in Error.Atomic.
+	// When joining a transaction, the enclosing savepoint or transaction is marked rollback-only instead.
+	ErrRollback = errors.New("Rollback")
+	// ErrRollbackOnly is the canonical error value when a transaction or savepoint is rolled back because it was
+	// marked with SetRollbackOnly() or SetSavepointRollbackOnly()
+	ErrRollbackOnly = errors.New("Transaction or savepoint was marked rollback-only")
+)
+
+func (q *querier) SetRollbackOnly() {
+	if q == nil || q.scope == nil {
+		return
+	}
+	q.scope.root().rollbackOnly.Store(true)
+}
+
+func (q *querier) SetSavepointRollbackOnly() {
+	if q == nil || q.scope == nil {
+		return
+	}
+	q.scope.savepoint().rollbackOnly.Store(true)
+}
+
+// joinErrs joins the errors, returning err as-is if there's no previous error
+func joinErrs(prev, err error) error {
+	if prev == nil {
+		return err
+	}
+	return errors.Join(prev, err)
+}
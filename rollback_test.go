@@ -0,0 +1,248 @@
+package satomic_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+import (
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+)
+
+import (
+	"github.com/dhui/satomic"
+	"github.com/dhui/satomic/satomictest"
+	"github.com/dhui/satomic/savepointers/mock"
+)
+
+func TestQuerierSetRollbackOnly(t *testing.T) {
+	rbErr := errors.New("rollback error")
+
+	testCases := []struct {
+		name              string
+		mocker            func(sqlmock.Sqlmock) sqlmock.Sqlmock
+		opts              satomic.AtomicOptions
+		mark              func(satomic.Querier)
+		expectedNestedErr *satomic.Error
+		expectedErrs      []error
+	}{
+		{name: "transaction", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectExec("RELEASE 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectRollback()
+			return m
+		}, mark: satomic.Querier.SetRollbackOnly, expectedNestedErr: nil,
+			expectedErrs: []error{satomic.ErrRollbackOnly}},
+		{name: "transaction - rollback error", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectExec("RELEASE 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectRollback().WillReturnError(rbErr)
+			return m
+		}, mark: satomic.Querier.SetRollbackOnly, expectedNestedErr: nil,
+			expectedErrs: []error{satomic.ErrRollbackOnly, rbErr}},
+		{name: "savepoint", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectExec("ROLLBACK TO 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectCommit()
+			return m
+		}, mark: satomic.Querier.SetSavepointRollbackOnly,
+			expectedNestedErr: satomictest.NewError(nil, satomic.ErrRollbackOnly), expectedErrs: nil},
+		{name: "joined", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectRollback()
+			return m
+		}, opts: satomic.AtomicOptions{Propagation: satomic.PropagationRequired},
+			mark: satomic.Querier.SetSavepointRollbackOnly, expectedNestedErr: nil,
+			expectedErrs: []error{satomic.ErrRollbackOnly}},
+	}
+
+	ctx := context.Background()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, _sqlmock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal("Error creating sqlmock:", err)
+			}
+			defer db.Close() // nolint:errcheck
+
+			_sqlmock = tc.mocker(_sqlmock)
+
+			q, err := satomic.NewQuerier(ctx, db, mock.NewSavepointer(io.Discard, true), sql.TxOptions{})
+			if err != nil {
+				t.Fatal("Error creating Querier:", err)
+			}
+
+			var rbCause error
+			atomicErr := q.Atomic(func(ctx context.Context, q satomic.Querier) error {
+				q.OnRollback(func(_ context.Context, err error) { rbCause = err })
+				if err := q.AtomicWithOptions(tc.opts, func(ctx context.Context, q satomic.Querier) error {
+					tc.mark(q)
+					return nil
+				}); !satomictest.ErrsEq(err, tc.expectedNestedErr) {
+					t.Errorf("Didn't get the expected nested error: %+v != %+v", err, tc.expectedNestedErr)
+				}
+				return nil
+			})
+			if len(tc.expectedErrs) == 0 {
+				if atomicErr != nil {
+					t.Error(atomicErr)
+				}
+			} else {
+				if atomicErr == nil || atomicErr.Err != nil {
+					t.Fatal("Didn't get the expected error:", atomicErr)
+				}
+				for _, expectedErr := range tc.expectedErrs {
+					if !errors.Is(atomicErr.Atomic, expectedErr) {
+						t.Errorf("Didn't get the expected error: %+v != %+v", atomicErr.Atomic, expectedErr)
+					}
+				}
+				if rbCause != satomic.ErrRollbackOnly {
+					t.Errorf("Didn't get the expected rollback cause: %+v != %+v", rbCause,
+						satomic.ErrRollbackOnly)
+				}
+			}
+
+			if err := _sqlmock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestQuerierSetRollbackOnlyNoTransaction(t *testing.T) {
+	db, _sqlmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal("Error creating sqlmock:", err)
+	}
+	defer db.Close() // nolint:errcheck
+
+	ctx := context.Background()
+	q, err := satomic.NewQuerier(ctx, db, mock.NewSavepointer(io.Discard, true), sql.TxOptions{})
+	if err != nil {
+		t.Fatal("Error creating Querier:", err)
+	}
+	q.SetRollbackOnly()
+	q.SetSavepointRollbackOnly()
+
+	_sqlmock.ExpectBegin()
+	_sqlmock.ExpectCommit()
+	if err := q.Atomic(func(context.Context, satomic.Querier) error { return nil }); err != nil {
+		t.Error(err)
+	}
+
+	if err := _sqlmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQuerierErrRollback(t *testing.T) {
+	wrappedErr := fmt.Errorf("dry run: %w", satomic.ErrRollback)
+	rbErr := errors.New("rollback error")
+
+	testCases := []struct {
+		name        string
+		mocker      func(sqlmock.Sqlmock) sqlmock.Sqlmock
+		savepoint   bool
+		joined      bool
+		cbErr       error
+		expectedErr *satomic.Error
+	}{
+		{name: "transaction", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectRollback()
+			return m
+		}, cbErr: satomic.ErrRollback, expectedErr: nil},
+		{name: "transaction - wrapped", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectRollback()
+			return m
+		}, cbErr: wrappedErr, expectedErr: nil},
+		{name: "transaction - rollback error", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectRollback().WillReturnError(rbErr)
+			return m
+		}, cbErr: satomic.ErrRollback, expectedErr: satomictest.NewError(satomic.ErrRollback, rbErr)},
+		{name: "savepoint", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectExec("ROLLBACK TO 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectCommit()
+			return m
+		}, savepoint: true, cbErr: satomic.ErrRollback, expectedErr: nil},
+		{name: "savepoint - rollback error", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectExec("ROLLBACK TO 1;").WillReturnError(rbErr)
+			m.ExpectCommit()
+			return m
+		}, savepoint: true, cbErr: wrappedErr, expectedErr: satomictest.NewError(wrappedErr, rbErr)},
+		{name: "joined", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectRollback()
+			return m
+		}, joined: true, cbErr: satomic.ErrRollback,
+			expectedErr: satomictest.NewError(nil, satomic.ErrRollbackOnly)},
+		{name: "joined - savepoint", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectExec("ROLLBACK TO 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+			m.ExpectCommit()
+			return m
+		}, savepoint: true, joined: true, cbErr: wrappedErr,
+			expectedErr: satomictest.NewError(nil, satomic.ErrRollbackOnly)},
+	}
+
+	ctx := context.Background()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, _sqlmock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal("Error creating sqlmock:", err)
+			}
+			defer db.Close() // nolint:errcheck
+
+			_sqlmock = tc.mocker(_sqlmock)
+
+			q, err := satomic.NewQuerier(ctx, db, mock.NewSavepointer(io.Discard, true), sql.TxOptions{})
+			if err != nil {
+				t.Fatal("Error creating Querier:", err)
+			}
+
+			rollback := func(context.Context, satomic.Querier) error { return tc.cbErr }
+			if tc.joined {
+				// The enclosing savepoint or transaction is rolled back instead
+				joined := rollback
+				rollback = func(ctx context.Context, q satomic.Querier) error {
+					if err := q.AtomicWithOptions(satomic.AtomicOptions{Propagation: satomic.PropagationRequired},
+						joined); err != nil {
+						t.Error("Didn't expect a joined error:", err)
+					}
+					return nil
+				}
+			}
+			if tc.savepoint {
+				if err := q.Atomic(func(ctx context.Context, q satomic.Querier) error {
+					if err := q.Atomic(rollback); !satomictest.ErrsEq(err, tc.expectedErr) {
+						t.Errorf("Didn't get the expected error: %+v != %+v", err, tc.expectedErr)
+					}
+					return nil
+				}); err != nil {
+					t.Error(err)
+				}
+			} else if err := q.Atomic(rollback); !satomictest.ErrsEq(err, tc.expectedErr) {
+				t.Errorf("Didn't get the expected error: %+v != %+v", err, tc.expectedErr)
+			}
+
+			if err := _sqlmock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
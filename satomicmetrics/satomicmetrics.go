@@ -0,0 +1,269 @@
+// Package satomicmetrics provides a satomic.Recorder that exposes transaction metrics via expvar and in the
+// Prometheus text format without any additional dependencies
+package satomicmetrics
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+import (
+	"github.com/dhui/satomic"
+)
+
+var (
+	// DurationBuckets are the upper bounds, in seconds, of the transaction duration histogram buckets
+	DurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	// DepthBuckets are the upper bounds of the depth histogram buckets
+	DepthBuckets = []float64{1, 2, 3, 4, 5, 8, 16}
+)
+
+// Histogram is a snapshot of the observations of a value
+type Histogram struct {
+	// Buckets are the upper bounds of the buckets
+	Buckets []float64 `json:"buckets"`
+	// Counts are the number of observations in each bucket. i.e. greater than the previous bucket's upper bound
+	// and less than or equal to the bucket's upper bound. The last count is for the observations that are greater
+	// than all of the upper bounds.
+	Counts []uint64 `json:"counts"`
+	// Count is the total number of observations
+	Count uint64 `json:"count"`
+	// Sum is the sum of the observations
+	Sum float64 `json:"sum"`
+}
+
+func newHistogram(buckets []float64) Histogram {
+	return Histogram{Buckets: buckets, Counts: make([]uint64, len(buckets)+1)}
+}
+
+func (h *Histogram) observe(v float64) {
+	i := sort.SearchFloat64s(h.Buckets, v)
+	h.Counts[i]++
+	h.Count++
+	h.Sum += v
+}
+
+func (h Histogram) clone() Histogram {
+	h.Counts = append([]uint64(nil), h.Counts...)
+	return h
+}
+
+// Snapshot holds the metrics for a single label
+type Snapshot struct {
+	TransactionsStarted    uint64 `json:"transactions_started"`
+	TransactionsCommitted  uint64 `json:"transactions_committed"`
+	TransactionsRolledBack uint64 `json:"transactions_rolled_back"`
+	// TransactionsUnknown counts the transactions whose commit outcome is unknown. See
+	// satomic.ErrCommitOutcomeUnknown
+	TransactionsUnknown  uint64 `json:"transactions_unknown"`
+	SavepointsCreated    uint64 `json:"savepoints_created"`
+	SavepointsReleased   uint64 `json:"savepoints_released"`
+	SavepointsRolledBack uint64 `json:"savepoints_rolled_back"`
+	SavepointsUnknown    uint64 `json:"savepoints_unknown"`
+	Retries              uint64 `json:"retries"`
+	// MaxDepth is the deepest that a transaction has been nested. See satomic.Querier.Depth()
+	MaxDepth int `json:"max_depth"`
+	// TransactionDuration is how long the transactions ran for in seconds
+	TransactionDuration Histogram `json:"transaction_duration_seconds"`
+	// Depth is the depth of each of the transactions and savepoints
+	Depth Histogram `json:"depth"`
+}
+
+// Metrics implements the satomic.Recorder interface, keeping the metrics for each label.
+// Metrics is safe for concurrent use.
+type Metrics struct {
+	mu     sync.Mutex
+	labels map[string]*Snapshot
+}
+
+// New creates a new Metrics
+func New() *Metrics {
+	return &Metrics{labels: map[string]*Snapshot{}}
+}
+
+// label returns the metrics for the label. m.mu must be held.
+func (m *Metrics) label(label string) *Snapshot {
+	s, ok := m.labels[label]
+	if !ok {
+		s = &Snapshot{TransactionDuration: newHistogram(DurationBuckets), Depth: newHistogram(DepthBuckets)}
+		m.labels[label] = s
+	}
+	return s
+}
+
+// RecordStart counts the started transaction or created savepoint
+func (m *Metrics) RecordStart(label string, depth int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.label(label)
+	if depth <= 1 {
+		s.TransactionsStarted++
+	} else {
+		s.SavepointsCreated++
+	}
+	if depth > s.MaxDepth {
+		s.MaxDepth = depth
+	}
+	s.Depth.observe(float64(depth))
+}
+
+// RecordEnd counts the transaction or savepoint's outcome and observes the transaction's duration
+func (m *Metrics) RecordEnd(label string, depth int, outcome satomic.Outcome, elapsed time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.label(label)
+	if depth > 1 {
+		switch outcome {
+		case satomic.OutcomeReleased:
+			s.SavepointsReleased++
+		case satomic.OutcomeRolledBack:
+			s.SavepointsRolledBack++
+		default:
+			s.SavepointsUnknown++
+		}
+		return
+	}
+	switch outcome {
+	case satomic.OutcomeCommitted:
+		s.TransactionsCommitted++
+	case satomic.OutcomeRolledBack:
+		s.TransactionsRolledBack++
+	default:
+		s.TransactionsUnknown++
+	}
+	s.TransactionDuration.observe(elapsed.Seconds())
+}
+
+// RecordRetry counts the retry
+func (m *Metrics) RecordRetry(label string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.label(label).Retries++
+}
+
+// Snapshot returns a copy of the metrics for each label
+func (m *Metrics) Snapshot() map[string]Snapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	snapshot := make(map[string]Snapshot, len(m.labels))
+	for label, s := range m.labels {
+		c := *s
+		c.TransactionDuration = s.TransactionDuration.clone()
+		c.Depth = s.Depth.clone()
+		snapshot[label] = c
+	}
+	return snapshot
+}
+
+// Var returns an expvar.Var with the metrics for each label. e.g. expvar.Publish("satomic", m.Var())
+func (m *Metrics) Var() expvar.Var {
+	return expvar.Func(func() interface{} { return m.Snapshot() })
+}
+
+// escapeLabelValue escapes a Prometheus label value
+func escapeLabelValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// prometheusWriter writes metrics in the Prometheus text format, keeping the first error
+type prometheusWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (pw *prometheusWriter) printf(format string, args ...interface{}) {
+	if pw.err == nil {
+		_, pw.err = fmt.Fprintf(pw.w, format, args...)
+	}
+}
+
+func (pw *prometheusWriter) header(name, typ, help string) {
+	pw.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func (pw *prometheusWriter) histogram(name, label string, h Histogram) {
+	var cumulative uint64
+	for i, upper := range h.Buckets {
+		cumulative += h.Counts[i]
+		pw.printf("%s_bucket{label=\"%s\",le=\"%s\"} %d\n", name, label, formatFloat(upper), cumulative)
+	}
+	pw.printf("%s_bucket{label=\"%s\",le=\"+Inf\"} %d\n", name, label, h.Count)
+	pw.printf("%s_sum{label=\"%s\"} %s\n", name, label, formatFloat(h.Sum))
+	pw.printf("%s_count{label=\"%s\"} %d\n", name, label, h.Count)
+}
+
+// WritePrometheus writes the metrics in the Prometheus text format
+//
+// https://prometheus.io/docs/instrumenting/exposition_formats/#text-based-format
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	snapshot := m.Snapshot()
+	labels := make([]string, 0, len(snapshot))
+	for label := range snapshot {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	counters := []struct {
+		name  string
+		help  string
+		value func(Snapshot) uint64
+	}{
+		{name: "satomic_transactions_started_total", help: "Transactions started.",
+			value: func(s Snapshot) uint64 { return s.TransactionsStarted }},
+		{name: "satomic_transactions_committed_total", help: "Transactions committed.",
+			value: func(s Snapshot) uint64 { return s.TransactionsCommitted }},
+		{name: "satomic_transactions_rolled_back_total", help: "Transactions rolled back.",
+			value: func(s Snapshot) uint64 { return s.TransactionsRolledBack }},
+		{name: "satomic_transactions_unknown_total", help: "Transactions with an unknown commit outcome.",
+			value: func(s Snapshot) uint64 { return s.TransactionsUnknown }},
+		{name: "satomic_savepoints_created_total", help: "Savepoints created.",
+			value: func(s Snapshot) uint64 { return s.SavepointsCreated }},
+		{name: "satomic_savepoints_released_total", help: "Savepoints released.",
+			value: func(s Snapshot) uint64 { return s.SavepointsReleased }},
+		{name: "satomic_savepoints_rolled_back_total", help: "Savepoints rolled back.",
+			value: func(s Snapshot) uint64 { return s.SavepointsRolledBack }},
+		{name: "satomic_savepoints_unknown_total", help: "Savepoints with an unknown release outcome.",
+			value: func(s Snapshot) uint64 { return s.SavepointsUnknown }},
+		{name: "satomic_retries_total", help: "Transactions retried.",
+			value: func(s Snapshot) uint64 { return s.Retries }},
+	}
+
+	pw := &prometheusWriter{w: w}
+	for _, c := range counters {
+		pw.header(c.name, "counter", c.help)
+		for _, label := range labels {
+			pw.printf("%s{label=\"%s\"} %d\n", c.name, escapeLabelValue(label), c.value(snapshot[label]))
+		}
+	}
+	pw.header("satomic_max_depth", "gauge", "Deepest that a transaction has been nested.")
+	for _, label := range labels {
+		pw.printf("satomic_max_depth{label=\"%s\"} %d\n", escapeLabelValue(label), snapshot[label].MaxDepth)
+	}
+	pw.header("satomic_transaction_duration_seconds", "histogram", "Transaction duration in seconds.")
+	for _, label := range labels {
+		pw.histogram("satomic_transaction_duration_seconds", escapeLabelValue(label),
+			snapshot[label].TransactionDuration)
+	}
+	pw.header("satomic_depth", "histogram", "Depth of the transactions and savepoints.")
+	for _, label := range labels {
+		pw.histogram("satomic_depth", escapeLabelValue(label), snapshot[label].Depth)
+	}
+	return pw.err
+}
+
+// ServeHTTP serves the metrics in the Prometheus text format
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WritePrometheus(w) // nolint:errcheck
+}
@@ -0,0 +1,163 @@
+package satomicmetrics_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+import (
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+)
+
+import (
+	"github.com/dhui/satomic"
+	"github.com/dhui/satomic/satomicmetrics"
+	"github.com/dhui/satomic/savepointers/mock"
+)
+
+func TestMetricsConcurrentUse(t *testing.T) {
+	const numQueriers = 20
+	const numTransactions = 10
+	cbErr := errors.New("cb error")
+
+	metrics := satomicmetrics.New()
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i := 0; i < numQueriers; i++ {
+		db, _sqlmock, err := sqlmock.New()
+		if err != nil {
+			t.Fatal("Error creating sqlmock:", err)
+		}
+		defer db.Close() // nolint:errcheck
+
+		for j := 0; j < numTransactions; j++ {
+			// Committed with a released savepoint
+			_sqlmock.ExpectBegin()
+			_sqlmock.ExpectExec(`SAVEPOINT \d+;`).WillReturnResult(sqlmock.NewResult(0, 0))
+			_sqlmock.ExpectExec(`RELEASE \d+;`).WillReturnResult(sqlmock.NewResult(0, 0))
+			_sqlmock.ExpectCommit()
+			// Retried once after being rolled back with a rolled back savepoint
+			_sqlmock.ExpectBegin()
+			_sqlmock.ExpectExec(`SAVEPOINT \d+;`).WillReturnResult(sqlmock.NewResult(0, 0))
+			_sqlmock.ExpectExec(`ROLLBACK TO \d+;`).WillReturnResult(sqlmock.NewResult(0, 0))
+			_sqlmock.ExpectRollback()
+			_sqlmock.ExpectBegin()
+			_sqlmock.ExpectCommit()
+		}
+
+		q, err := satomic.NewQuerier(ctx, db, mock.NewSavepointer(io.Discard, true), sql.TxOptions{},
+			satomic.WithRecorder(metrics))
+		if err != nil {
+			t.Fatal("Error creating Querier:", err)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < numTransactions; j++ {
+				if err := q.AtomicWithOptions(satomic.AtomicOptions{Label: "commit"},
+					func(ctx context.Context, q satomic.Querier) error {
+						if err := q.Atomic(func(context.Context, satomic.Querier) error { return nil }); err != nil {
+							return err
+						}
+						return nil
+					}); err != nil {
+					t.Error(err)
+				}
+				attempts := 0
+				policy := satomic.RetryPolicy{MaxAttempts: 2, Retryable: func(error) bool { return true },
+					Label: "retried"}
+				if err := q.AtomicWithRetry(policy, func(ctx context.Context, q satomic.Querier) error {
+					attempts++
+					if attempts > 1 {
+						return nil
+					}
+					if err := q.Atomic(func(context.Context, satomic.Querier) error { return cbErr }); err != nil {
+						return err
+					}
+					return nil
+				}); err != nil {
+					t.Error(err)
+				}
+			}
+			if err := _sqlmock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	total := uint64(numQueriers * numTransactions)
+	snapshot := metrics.Snapshot()
+	commit, retried := snapshot["commit"], snapshot["retried"]
+	if commit.TransactionsStarted != total || commit.TransactionsCommitted != total ||
+		commit.TransactionsRolledBack != 0 || commit.SavepointsCreated != total ||
+		commit.SavepointsReleased != total || commit.MaxDepth != 2 || commit.TransactionDuration.Count != total {
+		t.Errorf("Didn't get the expected metrics: %+v", commit)
+	}
+	if retried.TransactionsStarted != 2*total || retried.TransactionsCommitted != total ||
+		retried.TransactionsRolledBack != total || retried.SavepointsCreated != total ||
+		retried.SavepointsRolledBack != total || retried.Retries != total || retried.Depth.Count != 3*total {
+		t.Errorf("Didn't get the expected metrics: %+v", retried)
+	}
+}
+
+func TestMetricsPrometheus(t *testing.T) {
+	metrics := satomicmetrics.New()
+	metrics.RecordStart("transfer \"debit\"", 1)
+	metrics.RecordStart("transfer \"debit\"", 2)
+	metrics.RecordEnd("transfer \"debit\"", 2, satomic.OutcomeReleased, time.Millisecond)
+	metrics.RecordEnd("transfer \"debit\"", 1, satomic.OutcomeCommitted, 20*time.Millisecond)
+	metrics.RecordRetry("")
+
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Error("Didn't get the expected Content-Type:", ct)
+	}
+	body := rec.Body.String()
+	for _, expected := range []string{
+		"# TYPE satomic_transactions_started_total counter\n",
+		`satomic_transactions_started_total{label="transfer \"debit\""} 1` + "\n",
+		`satomic_transactions_committed_total{label="transfer \"debit\""} 1` + "\n",
+		`satomic_savepoints_released_total{label="transfer \"debit\""} 1` + "\n",
+		`satomic_retries_total{label=""} 1` + "\n",
+		`satomic_max_depth{label="transfer \"debit\""} 2` + "\n",
+		"# TYPE satomic_transaction_duration_seconds histogram\n",
+		`satomic_transaction_duration_seconds_bucket{label="transfer \"debit\"",le="0.01"} 0` + "\n",
+		`satomic_transaction_duration_seconds_bucket{label="transfer \"debit\"",le="0.025"} 1` + "\n",
+		`satomic_transaction_duration_seconds_bucket{label="transfer \"debit\"",le="+Inf"} 1` + "\n",
+		`satomic_transaction_duration_seconds_sum{label="transfer \"debit\""} 0.02` + "\n",
+		`satomic_depth_bucket{label="transfer \"debit\"",le="1"} 1` + "\n",
+		`satomic_depth_bucket{label="transfer \"debit\"",le="2"} 2` + "\n",
+		`satomic_depth_count{label="transfer \"debit\""} 2` + "\n",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Didn't get the expected line: %q in:\n%s", expected, body)
+		}
+	}
+}
+
+func TestMetricsVar(t *testing.T) {
+	metrics := satomicmetrics.New()
+	metrics.RecordStart("label", 1)
+	metrics.RecordEnd("label", 1, satomic.OutcomeRolledBack, time.Millisecond)
+
+	var snapshot map[string]satomicmetrics.Snapshot
+	if err := json.NewDecoder(bytes.NewBufferString(metrics.Var().String())).Decode(&snapshot); err != nil {
+		t.Fatal("Error decoding expvar:", err)
+	}
+	if s := snapshot["label"]; s.TransactionsStarted != 1 || s.TransactionsRolledBack != 1 ||
+		s.TransactionDuration.Count != 1 {
+		t.Errorf("Didn't get the expected metrics: %+v", s)
+	}
+}
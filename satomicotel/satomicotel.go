@@ -0,0 +1,300 @@
+// Package satomicotel provides OpenTelemetry tracing for satomic Queriers
+//
+// A span is created for each transaction and a child span for each savepoint, following the database semantic
+// conventions. A span can also be created for each statement. Since the Querier's transactions use the context that
+// the Querier was created with, the transaction spans are children of the span in that context.
+package satomicotel
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+import (
+	"github.com/dhui/satomic"
+)
+
+// TracerName is the name of the tracer used to create spans
+const TracerName = "github.com/dhui/satomic/satomicotel"
+
+// Attribute keys for the satomic specific span attributes
+const (
+	// DepthKey is the depth of the transaction or savepoint. See satomic.Querier.Depth()
+	DepthKey = attribute.Key("satomic.depth")
+	// LabelKey is the label of the transaction or savepoint. See satomic.AtomicOptions.Label
+	LabelKey = attribute.Key("satomic.label")
+	// SavepointKey is the name of the savepoint
+	SavepointKey = attribute.Key("satomic.savepoint")
+	// OutcomeKey is how the transaction or savepoint ended. e.g. OutcomeCommitted
+	OutcomeKey = attribute.Key("satomic.outcome")
+	// PhaseKey is the phase of the transaction or savepoint that failed. See satomic.Phase
+	PhaseKey = attribute.Key("satomic.phase")
+	// InTransactionKey is set if the statement is run in a transaction
+	InTransactionKey = attribute.Key("satomic.in_transaction")
+	// ErrorPhaseKey is the satomic.Error.Phase of a *satomic.Error. e.g. returned by a nested Atomic() call
+	ErrorPhaseKey = attribute.Key("satomic.error.phase")
+	// ErrorDepthKey is the satomic.Error.Depth of a *satomic.Error
+	ErrorDepthKey = attribute.Key("satomic.error.depth")
+)
+
+// Outcomes of a transaction or savepoint
+const (
+	OutcomeCommitted  = "committed"
+	OutcomeReleased   = "released"
+	OutcomeRolledBack = "rolled_back"
+	// OutcomeUnknown is used when the connection is lost while committing. See satomic.ErrCommitOutcomeUnknown
+	OutcomeUnknown = "unknown"
+)
+
+type config struct {
+	tracerProvider trace.TracerProvider
+	dbSystem       string
+	statements     bool
+}
+
+// Option configures the tracing
+type Option func(*config)
+
+// WithTracerProvider uses the TracerProvider to create spans instead of the global TracerProvider
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = tp
+	}
+}
+
+// WithDBSystem sets the db.system attribute of the spans. e.g. postgresql, mysql, mssql or sqlite
+func WithDBSystem(system string) Option {
+	return func(c *config) {
+		c.dbSystem = system
+	}
+}
+
+// WithStatements creates a span for each statement run by the Querier. See satomic.WithInterceptors()
+func WithStatements() Option {
+	return func(c *config) {
+		c.statements = true
+	}
+}
+
+func newConfig(opts []Option) config {
+	c := config{tracerProvider: otel.GetTracerProvider()}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&c)
+		}
+	}
+	return c
+}
+
+// tracer creates the spans for the satomic.Hooks and satomic.Interceptor
+type tracer struct {
+	satomic.NoopHooks
+	tracer   trace.Tracer
+	dbSystem string
+}
+
+func newTracer(c config) *tracer {
+	return &tracer{tracer: c.tracerProvider.Tracer(TracerName), dbSystem: c.dbSystem}
+}
+
+// QuerierOptions returns the options for tracing a satomic.Querier or satomicx.Querier.
+// e.g. satomic.NewQuerier(ctx, db, savepointer, txOpts, satomicotel.QuerierOptions()...)
+func QuerierOptions(opts ...Option) []satomic.Option {
+	c := newConfig(opts)
+	t := newTracer(c)
+	querierOpts := []satomic.Option{satomic.WithHooks(t)}
+	if c.statements {
+		querierOpts = append(querierOpts, satomic.WithInterceptors(t.intercept))
+	}
+	return querierOpts
+}
+
+// NewHooks returns satomic.Hooks that create a span for each transaction and savepoint. See satomic.WithHooks()
+func NewHooks(opts ...Option) satomic.Hooks {
+	return newTracer(newConfig(opts))
+}
+
+// NewInterceptor returns a satomic.Interceptor that creates a span for each statement.
+// See satomic.WithInterceptors()
+func NewInterceptor(opts ...Option) satomic.Interceptor {
+	return newTracer(newConfig(opts)).intercept
+}
+
+// Traceparent returns the W3C traceparent of the context's current span or "" if the context doesn't have a valid
+// span. It's intended for satomic.SQLCommentOptions.Traceparent. Within an Atomic() callback function, the current
+// span is the span of the transaction or savepoint, or of the statement if WithStatements() is used and
+// satomic.WithSQLComments() comes after QuerierOptions().
+func Traceparent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	return carrier.Get("traceparent")
+}
+
+// spanKey is the context key for the span of a transaction or savepoint. The span is kept separately from the
+// context's current span in case other satomic.Hooks start spans.
+type spanKey struct{}
+
+func spanFromContext(ctx context.Context) trace.Span {
+	if span, ok := ctx.Value(spanKey{}).(trace.Span); ok {
+		return span
+	}
+	// A non-recording span
+	return trace.SpanFromContext(context.Background())
+}
+
+func (t *tracer) attrs(info satomic.HookInfo) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, 4)
+	if t.dbSystem != "" {
+		attrs = append(attrs, semconv.DBSystemKey.String(t.dbSystem))
+	}
+	attrs = append(attrs, DepthKey.Int(info.Depth))
+	if info.SavepointName != "" {
+		attrs = append(attrs, SavepointKey.String(info.SavepointName))
+	}
+	if info.Label != "" {
+		attrs = append(attrs, LabelKey.String(info.Label))
+	}
+	return attrs
+}
+
+func (t *tracer) start(ctx context.Context, name string, info satomic.HookInfo) context.Context {
+	if info.Label != "" {
+		name = info.Label
+	}
+	ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(t.attrs(info)...))
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// addEvent records the statement run for an operation on the transaction or savepoint. Nothing is recorded if no
+// statement was run. See satomic.WithLazyBegin()
+func addEvent(span trace.Span, operation string, info satomic.HookInfo) {
+	if info.Statement == "" {
+		return
+	}
+	span.AddEvent(operation, trace.WithAttributes(semconv.DBOperationKey.String(operation),
+		semconv.DBStatementKey.String(info.Statement)))
+}
+
+// recordError records the error along with the phase that it occurred in and the details of any *satomic.Error
+func recordError(span trace.Span, phase satomic.Phase, err error) {
+	attrs := []attribute.KeyValue{PhaseKey.String(phase.String())}
+	var satomicErr *satomic.Error
+	if errors.As(err, &satomicErr) && satomicErr != nil {
+		attrs = append(attrs, ErrorPhaseKey.String(satomicErr.Phase.String()), ErrorDepthKey.Int(satomicErr.Depth))
+	}
+	span.RecordError(err, trace.WithAttributes(attrs...))
+	span.SetStatus(codes.Error, err.Error())
+}
+
+func (t *tracer) BeforeBegin(ctx context.Context, info satomic.HookInfo) context.Context {
+	return t.start(ctx, "transaction", info)
+}
+
+func (t *tracer) AfterBegin(ctx context.Context, info satomic.HookInfo) {
+	span := spanFromContext(ctx)
+	addEvent(span, "BEGIN", info)
+	if info.Err != nil {
+		recordError(span, satomic.PhaseBegin, info.Err)
+	}
+}
+
+func (t *tracer) BeforeSavepoint(ctx context.Context, info satomic.HookInfo) context.Context {
+	return t.start(ctx, "savepoint", info)
+}
+
+func (t *tracer) AfterSavepoint(ctx context.Context, info satomic.HookInfo) {
+	span := spanFromContext(ctx)
+	addEvent(span, "SAVEPOINT", info)
+	if info.Err != nil {
+		recordError(span, satomic.PhaseSavepointCreate, info.Err)
+	}
+}
+
+func (t *tracer) AfterCommit(ctx context.Context, info satomic.HookInfo) {
+	span := spanFromContext(ctx)
+	addEvent(span, "COMMIT", info)
+	switch {
+	case info.Err == nil:
+		span.SetAttributes(OutcomeKey.String(OutcomeCommitted))
+	case errors.Is(info.Err, satomic.ErrCommitOutcomeUnknown):
+		span.SetAttributes(OutcomeKey.String(OutcomeUnknown))
+		recordError(span, satomic.PhaseCommit, info.Err)
+	default:
+		span.SetAttributes(OutcomeKey.String(OutcomeRolledBack))
+		recordError(span, satomic.PhaseCommit, info.Err)
+	}
+	span.End()
+}
+
+func (t *tracer) AfterRollback(ctx context.Context, info satomic.HookInfo) {
+	span := spanFromContext(ctx)
+	if info.SavepointName != "" {
+		addEvent(span, "ROLLBACK TO SAVEPOINT", info)
+	} else {
+		addEvent(span, "ROLLBACK", info)
+	}
+	span.SetAttributes(OutcomeKey.String(OutcomeRolledBack))
+	// An intentional rollback isn't an error. See satomic.ErrRollback
+	if info.Cause != nil && !errors.Is(info.Cause, satomic.ErrRollback) {
+		recordError(span, info.CausePhase, info.Cause)
+	}
+	if info.Err != nil {
+		recordError(span, satomic.PhaseRollback, info.Err)
+	}
+	span.End()
+}
+
+func (t *tracer) OnRelease(ctx context.Context, info satomic.HookInfo) {
+	span := spanFromContext(ctx)
+	addEvent(span, "RELEASE SAVEPOINT", info)
+	span.SetAttributes(OutcomeKey.String(OutcomeReleased))
+	if info.Err != nil {
+		recordError(span, satomic.PhaseRelease, info.Err)
+	}
+	span.End()
+}
+
+// operation returns the database operation of the query. i.e. its first keyword
+func operation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "query"
+	}
+	return strings.ToUpper(strings.TrimRight(fields[0], ";"))
+}
+
+func (t *tracer) intercept(ctx context.Context, info satomic.StatementInfo, next satomic.StatementRunner) error {
+	op := operation(info.Query)
+	attrs := make([]attribute.KeyValue, 0, 7)
+	if t.dbSystem != "" {
+		attrs = append(attrs, semconv.DBSystemKey.String(t.dbSystem))
+	}
+	attrs = append(attrs, semconv.DBOperationKey.String(op), semconv.DBStatementKey.String(info.Query),
+		InTransactionKey.Bool(info.InTransaction), DepthKey.Int(info.Depth))
+	if info.SavepointName != "" {
+		attrs = append(attrs, SavepointKey.String(info.SavepointName))
+	}
+	if info.Label != "" {
+		attrs = append(attrs, LabelKey.String(info.Label))
+	}
+	ctx, span := t.tracer.Start(ctx, op, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	defer span.End()
+
+	err := next(ctx, info.Query, info.Args...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,301 @@
+package satomicotel_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"testing"
+)
+
+import (
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+import (
+	"github.com/dhui/satomic"
+	"github.com/dhui/satomic/satomicotel"
+	"github.com/dhui/satomic/satomicx"
+	"github.com/dhui/satomic/savepointers/mock"
+)
+
+func attr(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func eventNames(span sdktrace.ReadOnlySpan) []string {
+	names := []string{}
+	for _, e := range span.Events() {
+		if e.Name != "exception" {
+			names = append(names, e.Name)
+		}
+	}
+	return names
+}
+
+// errorPhase returns the phase of the error recorded by the span
+func errorPhase(span sdktrace.ReadOnlySpan) string {
+	for _, e := range span.Events() {
+		if e.Name != "exception" {
+			continue
+		}
+		for _, kv := range e.Attributes {
+			if kv.Key == satomicotel.PhaseKey {
+				return kv.Value.AsString()
+			}
+		}
+	}
+	return ""
+}
+
+func TestQuerier(t *testing.T) {
+	cbErr := errors.New("cb error")
+
+	db, _sqlmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal("Error creating sqlmock:", err)
+	}
+	defer db.Close() // nolint:errcheck
+
+	_sqlmock.ExpectBegin()
+	_sqlmock.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectExec("UPDATE 1;").WillReturnResult(sqlmock.NewResult(0, 1))
+	_sqlmock.ExpectExec("ROLLBACK TO 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectCommit()
+
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	ctx, root := tp.Tracer("test").Start(context.Background(), "root")
+
+	q, err := satomic.NewQuerier(ctx, db, mock.NewSavepointer(io.Discard, true), sql.TxOptions{},
+		satomicotel.QuerierOptions(satomicotel.WithTracerProvider(tp), satomicotel.WithDBSystem("postgresql"),
+			satomicotel.WithStatements())...)
+	if err != nil {
+		t.Fatal("Error creating Querier:", err)
+	}
+
+	if err := q.AtomicWithOptions(satomic.AtomicOptions{Label: "checkout"},
+		func(ctx context.Context, q satomic.Querier) error {
+			if err := q.Atomic(func(ctx context.Context, q satomic.Querier) error {
+				if _, err := q.ExecContext(ctx, "UPDATE 1;"); err != nil {
+					return err
+				}
+				return cbErr
+			}); !errors.Is(err, cbErr) {
+				t.Errorf("Didn't get the expected error: %+v doesn't wrap %v", err, cbErr)
+			}
+			return nil
+		}); err != nil {
+		t.Error(err)
+	}
+	root.End()
+
+	spans := sr.Ended()
+	if len(spans) != 4 {
+		t.Fatalf("Didn't get the expected number of spans: %d != 4", len(spans))
+	}
+	stmtSpan, savepointSpan, txSpan := spans[0], spans[1], spans[2]
+
+	if stmtSpan.Name() != "UPDATE" || attr(stmtSpan, "db.statement").AsString() != "UPDATE 1;" ||
+		attr(stmtSpan, "db.operation").AsString() != "UPDATE" || attr(stmtSpan, "db.system").AsString() != "postgresql" ||
+		attr(stmtSpan, satomicotel.DepthKey).AsInt64() != 2 {
+		t.Errorf("Didn't get the expected statement span: %s %v", stmtSpan.Name(), stmtSpan.Attributes())
+	}
+	if stmtSpan.Parent().SpanID() != savepointSpan.SpanContext().SpanID() {
+		t.Error("The statement span isn't a child of the savepoint span")
+	}
+
+	if savepointSpan.Name() != "checkout" || attr(savepointSpan, satomicotel.LabelKey).AsString() != "checkout" ||
+		attr(savepointSpan, satomicotel.OutcomeKey).AsString() != satomicotel.OutcomeRolledBack ||
+		savepointSpan.Status().Code != codes.Error || savepointSpan.Status().Description != cbErr.Error() {
+		t.Errorf("Didn't get the expected savepoint span: %s %v %v", savepointSpan.Name(),
+			savepointSpan.Attributes(), savepointSpan.Status())
+	}
+	if events := eventNames(savepointSpan); len(events) != 2 || events[0] != "SAVEPOINT" ||
+		events[1] != "ROLLBACK TO SAVEPOINT" {
+		t.Errorf("Didn't get the expected savepoint events: %v", events)
+	}
+	if savepointSpan.Parent().SpanID() != txSpan.SpanContext().SpanID() {
+		t.Error("The savepoint span isn't a child of the transaction span")
+	}
+
+	if txSpan.Name() != "checkout" || attr(txSpan, satomicotel.LabelKey).AsString() != "checkout" ||
+		attr(txSpan, satomicotel.OutcomeKey).AsString() != satomicotel.OutcomeCommitted ||
+		txSpan.Status().Code != codes.Unset {
+		t.Errorf("Didn't get the expected transaction span: %s %v %v", txSpan.Name(), txSpan.Attributes(),
+			txSpan.Status())
+	}
+	if events := eventNames(txSpan); len(events) != 2 || events[0] != "BEGIN" || events[1] != "COMMIT" {
+		t.Errorf("Didn't get the expected transaction events: %v", events)
+	}
+	if txSpan.Parent().SpanID() != root.SpanContext().SpanID() {
+		t.Error("The transaction span isn't a child of the Querier's span")
+	}
+
+	if err := _sqlmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQuerierx(t *testing.T) {
+	cbErr := errors.New("cb error")
+
+	testCases := []struct {
+		name           string
+		err            error
+		rollbackOnly   bool
+		expectedStatus codes.Code
+		expectedPhase  string
+	}{
+		{name: "error", err: cbErr, expectedStatus: codes.Error, expectedPhase: "callback"},
+		{name: "ErrRollback", err: satomic.ErrRollback, expectedStatus: codes.Unset, expectedPhase: ""},
+		{name: "rollback only", err: nil, rollbackOnly: true, expectedStatus: codes.Error, expectedPhase: "commit"},
+	}
+
+	ctx := context.Background()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, _sqlmock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal("Error creating sqlmock:", err)
+			}
+			defer db.Close() // nolint:errcheck
+
+			_sqlmock.ExpectBegin()
+			_sqlmock.ExpectQuery("SELECT 1;").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			_sqlmock.ExpectRollback()
+
+			sr := tracetest.NewSpanRecorder()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+			q, err := satomicx.NewQuerier(ctx, sqlx.NewDb(db, "sqlmock"), mock.NewSavepointer(io.Discard, true),
+				sql.TxOptions{}, satomic.WithHooks(satomicotel.NewHooks(satomicotel.WithTracerProvider(tp))),
+				satomic.WithInterceptors(satomicotel.NewInterceptor(satomicotel.WithTracerProvider(tp))))
+			if err != nil {
+				t.Fatal("Error creating Querier:", err)
+			}
+
+			atomicErr := q.Atomicx(func(ctx context.Context, q satomicx.Querier) error {
+				var id int
+				if err := q.GetContext(ctx, &id, "SELECT 1;"); err != nil {
+					return err
+				}
+				if tc.rollbackOnly {
+					q.SetRollbackOnly()
+				}
+				return tc.err
+			})
+			switch {
+			case tc.rollbackOnly:
+				if !errors.Is(atomicErr, satomic.ErrRollbackOnly) {
+					t.Errorf("Didn't get the expected error: %+v doesn't wrap %v", atomicErr, satomic.ErrRollbackOnly)
+				}
+			case tc.err == satomic.ErrRollback:
+				if atomicErr != nil {
+					t.Error("Unexpected error:", atomicErr)
+				}
+			case !errors.Is(atomicErr, tc.err):
+				t.Errorf("Didn't get the expected error: %+v doesn't wrap %v", atomicErr, tc.err)
+			}
+
+			spans := sr.Ended()
+			if len(spans) != 2 {
+				t.Fatalf("Didn't get the expected number of spans: %d != 2", len(spans))
+			}
+			stmtSpan, txSpan := spans[0], spans[1]
+			if stmtSpan.Name() != "SELECT" || stmtSpan.Parent().SpanID() != txSpan.SpanContext().SpanID() {
+				t.Errorf("Didn't get the expected statement span: %s %v", stmtSpan.Name(), stmtSpan.Attributes())
+			}
+			if txSpan.Name() != "transaction" ||
+				attr(txSpan, satomicotel.OutcomeKey).AsString() != satomicotel.OutcomeRolledBack ||
+				txSpan.Status().Code != tc.expectedStatus || errorPhase(txSpan) != tc.expectedPhase {
+				t.Errorf("Didn't get the expected transaction span: %s %v %v", txSpan.Name(), txSpan.Attributes(),
+					txSpan.Status())
+			}
+
+			if err := _sqlmock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestTraceparent(t *testing.T) {
+	var queries []string
+	db, _sqlmock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherFunc(
+		func(_, actual string) error {
+			queries = append(queries, actual)
+			return nil
+		})))
+	if err != nil {
+		t.Fatal("Error creating sqlmock:", err)
+	}
+	defer db.Close() // nolint:errcheck
+
+	_sqlmock.ExpectBegin()
+	_sqlmock.ExpectExec("SAVEPOINT").WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectExec("UPDATE").WillReturnResult(sqlmock.NewResult(0, 1))
+	_sqlmock.ExpectExec("RELEASE").WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectCommit()
+
+	ctx := context.Background()
+	if traceparent := satomicotel.Traceparent(ctx); traceparent != "" {
+		t.Errorf("Didn't get the expected traceparent for a context without a span: %q", traceparent)
+	}
+
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	opts := append(satomicotel.QuerierOptions(satomicotel.WithTracerProvider(tp), satomicotel.WithStatements()),
+		satomic.WithSQLComments(satomic.SQLCommentOptions{Traceparent: satomicotel.Traceparent}))
+	q, err := satomic.NewQuerier(ctx, db, mock.NewSavepointer(io.Discard, true), sql.TxOptions{}, opts...)
+	if err != nil {
+		t.Fatal("Error creating Querier:", err)
+	}
+
+	if err := q.Atomic(func(ctx context.Context, q satomic.Querier) error {
+		if err := q.Atomic(func(ctx context.Context, q satomic.Querier) error {
+			_, err := q.ExecContext(ctx, "UPDATE 1;")
+			return err
+		}); err != nil {
+			return err
+		}
+		return nil
+	}); err != nil {
+		t.Error(err)
+	}
+
+	spans := sr.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("Didn't get the expected number of spans: %d != 3", len(spans))
+	}
+	traceparent := func(span sdktrace.ReadOnlySpan) string {
+		return "00-" + span.SpanContext().TraceID().String() + "-" + span.SpanContext().SpanID().String() + "-01"
+	}
+	stmtSpan, savepointSpan := spans[0], spans[1]
+	expectedQueries := []string{
+		"SAVEPOINT 1 /*depth='2',traceparent='" + traceparent(savepointSpan) + "'*/;",
+		"UPDATE 1 /*depth='2',traceparent='" + traceparent(stmtSpan) + "'*/;",
+		"RELEASE 1 /*depth='2',traceparent='" + traceparent(savepointSpan) + "'*/;",
+	}
+	if len(queries) != len(expectedQueries) {
+		t.Fatalf("Didn't get the expected queries: %q != %q", queries, expectedQueries)
+	}
+	for i := range queries {
+		if queries[i] != expectedQueries[i] {
+			t.Errorf("Didn't get the expected query: %q != %q", queries[i], expectedQueries[i])
+		}
+	}
+
+	if err := _sqlmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
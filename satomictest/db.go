@@ -0,0 +1,93 @@
+package satomictest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+import (
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+)
+
+// ErrConnBusy is returned by the connections of a DB created by NewBusyConnDB() when a statement is run while rows
+// are still being read
+var ErrConnBusy = errors.New("Connection is busy reading rows")
+
+var busyConnDBs atomic.Int64
+
+// NewBusyConnDB creates a sqlmock DB whose connections can't run a statement while rows are still being read, like
+// most drivers. e.g. lib/pq and go-sql-driver/mysql. ErrConnBusy is returned instead.
+func NewBusyConnDB() (*sql.DB, sqlmock.Sqlmock, error) {
+	dsn := fmt.Sprintf("satomictest_busy_%d", busyConnDBs.Add(1))
+	db, mock, err := sqlmock.NewWithDSN(dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sql.OpenDB(&busyConnector{db: db, dsn: dsn}), mock, nil
+}
+
+// busyConnector wraps the connections of the sqlmock DB
+type busyConnector struct {
+	db  *sql.DB
+	dsn string
+}
+
+func (c *busyConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.db.Driver().Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &busyConn{Conn: conn}, nil
+}
+
+func (c *busyConnector) Driver() driver.Driver { return c.db.Driver() }
+
+// Close closes the sqlmock DB when the DB is closed
+func (c *busyConnector) Close() error { return c.db.Close() }
+
+// busyConn tracks the rows being read from the connection
+type busyConn struct {
+	driver.Conn
+	rows atomic.Int32
+}
+
+func (c *busyConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+}
+
+func (c *busyConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if c.rows.Load() > 0 {
+		return nil, ErrConnBusy
+	}
+	return c.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+}
+
+func (c *busyConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if c.rows.Load() > 0 {
+		return nil, ErrConnBusy
+	}
+	rows, err := c.Conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	c.rows.Add(1)
+	return &busyRows{Rows: rows, conn: c}, nil
+}
+
+// busyRows marks the connection as no longer busy once the rows are closed
+type busyRows struct {
+	driver.Rows
+	conn   *busyConn
+	closed atomic.Bool
+}
+
+func (r *busyRows) Close() error {
+	if r.closed.CompareAndSwap(false, true) {
+		r.conn.rows.Add(-1)
+	}
+	return r.Rows.Close()
+}
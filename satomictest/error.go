@@ -5,13 +5,14 @@ import (
 	"github.com/dhui/satomic"
 )
 
-// ErrsEq determines if the two *satomic.Errors are equal
+// ErrsEq determines if the two *satomic.Errors have the same Err and Atomic errors.
+// The Phase and Depth aren't compared.
 func ErrsEq(a, b *satomic.Error) bool {
 	if a == b {
 		return true
 	}
 	if a != nil && b != nil {
-		return *a == *b
+		return a.Err == b.Err && a.Atomic == b.Atomic
 	}
 	return false
 }
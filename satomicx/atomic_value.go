@@ -0,0 +1,34 @@
+package satomicx
+
+import (
+	"context"
+)
+
+import (
+	"github.com/dhui/satomic"
+)
+
+// AtomicValue is like Querier.Atomicx() but also returns the value computed by the callback function.
+// The zero value is returned if the transaction or savepoint is rolled back or can't be committed or released.
+func AtomicValue[T any](q Querier, f func(context.Context, Querier) (T, error)) (T, *satomic.Error) {
+	var v T
+	if q == nil {
+		return v, &satomic.Error{Atomic: satomic.ErrNilQuerier}
+	}
+	if f == nil {
+		return v, q.Atomicx(nil)
+	}
+
+	if err := q.Atomicx(func(ctx context.Context, q Querier) error {
+		val, cbErr := f(ctx, q)
+		if cbErr != nil {
+			return cbErr
+		}
+		v = val
+		return nil
+	}); err != nil {
+		var zero T
+		return zero, err
+	}
+	return v, nil
+}
@@ -0,0 +1,81 @@
+package satomicx_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"testing"
+)
+
+import (
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+)
+
+import (
+	"github.com/dhui/satomic"
+	"github.com/dhui/satomic/satomictest"
+	"github.com/dhui/satomic/satomicx"
+	"github.com/dhui/satomic/savepointers/mock"
+)
+
+func TestAtomicValue(t *testing.T) {
+	type testStruct struct{ ID int }
+
+	selectErr := errors.New("select error")
+
+	testCases := []struct {
+		name          string
+		mocker        func(sqlmock.Sqlmock) sqlmock.Sqlmock
+		expectedValue testStruct
+		expectedErr   *satomic.Error
+	}{
+		{name: "success", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectQuery("SELECT 1;").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			m.ExpectCommit()
+			return m
+		}, expectedValue: testStruct{ID: 1}, expectedErr: nil},
+		{name: "callback error", mocker: func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
+			m.ExpectBegin()
+			m.ExpectQuery("SELECT 1;").WillReturnError(selectErr)
+			m.ExpectRollback()
+			return m
+		}, expectedValue: testStruct{}, expectedErr: satomictest.NewError(selectErr, nil)},
+	}
+
+	ctx := context.Background()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, _sqlmock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal("Error creating sqlmock:", err)
+			}
+			defer db.Close() // nolint:errcheck
+
+			_sqlmock = tc.mocker(_sqlmock)
+
+			q, err := satomicx.NewQuerier(ctx, sqlx.NewDb(db, ""), mock.NewSavepointer(io.Discard, true),
+				sql.TxOptions{})
+			if err != nil {
+				t.Fatal("Error creating Querier:", err)
+			}
+			v, atomicErr := satomicx.AtomicValue(q, func(ctx context.Context, q satomicx.Querier) (testStruct, error) {
+				var v testStruct
+				err := q.GetContext(ctx, &v, "SELECT 1;")
+				return v, err
+			})
+			if !satomictest.ErrsEq(atomicErr, tc.expectedErr) {
+				t.Errorf("Didn't get the expected error: %+v != %+v", atomicErr, tc.expectedErr)
+			}
+			if v != tc.expectedValue {
+				t.Errorf("Didn't get the expected value: %+v != %+v", v, tc.expectedValue)
+			}
+
+			if err := _sqlmock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package satomicx
+
+import (
+	"context"
+)
+
+import (
+	"github.com/dhui/satomic"
+)
+
+// WithQuerier returns a copy of the context that carries the given Querier. Atomicx() calls the callback function
+// with a context that carries the callback function's Querier.
+func WithQuerier(ctx context.Context, q Querier) context.Context {
+	return satomic.WithQuerier(ctx, q)
+}
+
+// QuerierFromContext returns the Querier carried by the context. If the context doesn't carry a Querier that
+// supports sqlx, e.g. it's not from within an Atomicx() callback function, the fallback Querier is returned.
+func QuerierFromContext(ctx context.Context, fallback Querier) Querier {
+	if q, ok := satomic.QuerierFromContext(ctx, nil).(Querier); ok {
+		return q
+	}
+	return fallback
+}
@@ -0,0 +1,62 @@
+package satomicx_test
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"testing"
+)
+
+import (
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+)
+
+import (
+	"github.com/dhui/satomic"
+	"github.com/dhui/satomic/satomicx"
+	"github.com/dhui/satomic/savepointers/mock"
+)
+
+func TestQuerierFromContext(t *testing.T) {
+	db, _sqlmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal("Error creating sqlmock:", err)
+	}
+	defer db.Close() // nolint:errcheck
+
+	_sqlmock.ExpectBegin()
+	_sqlmock.ExpectQuery("SELECT 1;").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	_sqlmock.ExpectCommit()
+
+	ctx := context.Background()
+	root, err := satomicx.NewQuerier(ctx, sqlx.NewDb(db, ""), mock.NewSavepointer(io.Discard, true),
+		sql.TxOptions{})
+	if err != nil {
+		t.Fatal("Error creating Querier:", err)
+	}
+
+	if q := satomicx.QuerierFromContext(ctx, root); q != root {
+		t.Error("Didn't get the fallback Querier from a context without a Querier")
+	}
+	if q := satomicx.QuerierFromContext(satomicx.WithQuerier(ctx, root), nil); q != root {
+		t.Error("Didn't get the Querier stored with WithQuerier()")
+	}
+
+	if err := root.Atomicx(func(ctx context.Context, q satomicx.Querier) error {
+		if fromCtx := satomicx.QuerierFromContext(ctx, root); fromCtx != q {
+			t.Error("The callback function's context doesn't carry the callback function's Querier")
+		}
+		if fromCtx := satomic.QuerierFromContext(ctx, nil); fromCtx != q {
+			t.Error("The callback function's context doesn't carry the callback function's Querier for satomic")
+		}
+		var dest struct{ ID int }
+		return satomicx.QuerierFromContext(ctx, root).GetContext(ctx, &dest, "SELECT 1;")
+	}); err != nil {
+		t.Error(err)
+	}
+
+	if err := _sqlmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
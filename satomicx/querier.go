@@ -5,6 +5,8 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"io"
+	"sync"
 )
 
 import (
@@ -13,6 +15,8 @@ import (
 
 import (
 	"github.com/dhui/satomic"
+	"github.com/dhui/satomic/internal/bufsql"
+	"github.com/dhui/satomic/internal/errsql"
 	"github.com/dhui/satomic/savepointers"
 )
 
@@ -36,6 +40,10 @@ type QuerierBase interface {
 	QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error)
 	QueryRowx(query string, args ...interface{}) *sqlx.Row
 	QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row
+	Preparex(query string) (*sqlx.Stmt, error)
+	PreparexContext(ctx context.Context, query string) (*sqlx.Stmt, error)
+	PrepareNamed(query string) (*sqlx.NamedStmt, error)
+	PrepareNamedContext(ctx context.Context, query string) (*sqlx.NamedStmt, error)
 }
 
 // Querier provides an interface to interact with a SQL DB within an atomic transaction or savepoint
@@ -43,13 +51,79 @@ type Querier interface {
 	QuerierBase
 	satomic.Querier
 
+	// Txx returns the Querier's transaction or nil if the Querier isn't in a transaction. See Tx()
+	Txx() *sqlx.Tx
+
 	Atomicx(f func(context.Context, Querier) error) *satomic.Error
+	// AtomicxWithOptions is like AtomicWithOptions() but provides a Querier that supports sqlx to the callback
+	// function
+	AtomicxWithOptions(opts satomic.AtomicOptions, f func(context.Context, Querier) error) *satomic.Error
+	// AtomicxNamed is like AtomicNamed() but provides a Querier that supports sqlx to the callback function
+	AtomicxNamed(label string, f func(context.Context, Querier) error) *satomic.Error
+	// AtomicxWithRetry is like AtomicWithRetry() but provides a Querier that supports sqlx to the callback function
+	AtomicxWithRetry(policy satomic.RetryPolicy, f func(context.Context, Querier) error) *satomic.Error
+}
+
+// txRegistry maps the transactions created by the TxCreator to their *sqlx.Tx
+type txRegistry struct {
+	mu  sync.Mutex
+	txs map[*sql.Tx]*sqlx.Tx
+}
+
+func (r *txRegistry) add(tx *sqlx.Tx) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.txs == nil {
+		r.txs = map[*sql.Tx]*sqlx.Tx{}
+	}
+	r.txs[tx.Tx] = tx
+}
+
+func (r *txRegistry) get(tx *sql.Tx) *sqlx.Tx {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.txs[tx]
+}
+
+func (r *txRegistry) remove(tx *sql.Tx) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.txs, tx)
+}
+
+// preparedStmts holds the statements prepared within an Atomicx() callback function
+type preparedStmts struct {
+	mu    sync.Mutex
+	stmts []io.Closer
+}
+
+func (ps *preparedStmts) add(stmt io.Closer) {
+	if ps == nil {
+		return
+	}
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.stmts = append(ps.stmts, stmt)
+}
+
+func (ps *preparedStmts) close() {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	for _, stmt := range ps.stmts {
+		stmt.Close() // nolint:errcheck
+	}
+	ps.stmts = nil
 }
 
 type wrappedQuerier struct {
 	satomic.Querier
 	db *sqlx.DB
-	tx *sqlx.Tx
+	// tx is the *sqlx.Tx for the satomic.Querier's transaction, if known
+	tx  *sqlx.Tx
+	txs *txRegistry
+	// stmts are the statements prepared within the Atomicx() callback function. They're closed when the callback
+	// function returns.
+	stmts *preparedStmts
 }
 
 func (wq *wrappedQuerier) Get(dest interface{}, query string, args ...interface{}) error {
@@ -64,10 +138,18 @@ func (wq *wrappedQuerier) GetContext(ctx context.Context, dest interface{}, quer
 	if wq.db == nil {
 		return satomic.ErrInvalidQuerier
 	}
-	if wq.tx == nil {
-		return wq.db.GetContext(ctx, dest, query, args...)
+	tx, release, err := wq.acquire()
+	if err != nil {
+		return err
 	}
-	return wq.tx.GetContext(ctx, dest, query, args...)
+	defer release()
+	return satomic.Intercept(ctx, wq.Querier, "GetContext", query, args, func(ctx context.Context, query string,
+		args ...interface{}) error {
+		if tx == nil {
+			return wq.db.GetContext(ctx, dest, query, args...)
+		}
+		return tx.GetContext(ctx, dest, query, args...)
+	})
 }
 
 func (wq *wrappedQuerier) Select(dest interface{}, query string, args ...interface{}) error {
@@ -82,10 +164,18 @@ func (wq *wrappedQuerier) SelectContext(ctx context.Context, dest interface{}, q
 	if wq.db == nil {
 		return satomic.ErrInvalidQuerier
 	}
-	if wq.tx == nil {
-		return wq.db.SelectContext(ctx, dest, query, args...)
+	tx, release, err := wq.acquire()
+	if err != nil {
+		return err
 	}
-	return wq.tx.SelectContext(ctx, dest, query, args...)
+	defer release()
+	return satomic.Intercept(ctx, wq.Querier, "SelectContext", query, args, func(ctx context.Context, query string,
+		args ...interface{}) error {
+		if tx == nil {
+			return wq.db.SelectContext(ctx, dest, query, args...)
+		}
+		return tx.SelectContext(ctx, dest, query, args...)
+	})
 }
 
 func (wq *wrappedQuerier) Queryx(query string, args ...interface{}) (*sqlx.Rows, error) {
@@ -100,10 +190,32 @@ func (wq *wrappedQuerier) QueryxContext(ctx context.Context, query string, args
 	if wq.db == nil {
 		return nil, satomic.ErrInvalidQuerier
 	}
-	if wq.tx == nil {
-		return wq.db.QueryxContext(ctx, query, args...)
+	tx, release, err := wq.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	var rows *sqlx.Rows
+	err = satomic.Intercept(ctx, wq.Querier, "QueryxContext", query, args, func(ctx context.Context, query string,
+		args ...interface{}) (queryErr error) {
+		if tx == nil {
+			rows, queryErr = wq.db.QueryxContext(ctx, query, args...)
+		} else {
+			rows, queryErr = tx.QueryxContext(ctx, query, args...)
+			if queryErr == nil && satomic.BufferRows(wq.Querier) {
+				// The transaction can be used by other goroutines once it's released, so the rows are read first
+				rows, queryErr = bufferedDB(tx).QueryxContext(ctx, "", bufsql.Read(rows.Rows))
+			}
+		}
+		return queryErr
+	})
+	if err != nil {
+		if rows != nil {
+			rows.Close() // nolint:errcheck
+		}
+		return nil, err
 	}
-	return wq.tx.QueryxContext(ctx, query, args...)
+	return rows, nil
 }
 
 func (wq *wrappedQuerier) QueryRowx(query string, args ...interface{}) *sqlx.Row {
@@ -112,25 +224,179 @@ func (wq *wrappedQuerier) QueryRowx(query string, args ...interface{}) *sqlx.Row
 
 func (wq *wrappedQuerier) QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row {
 	if wq == nil {
-		return nil
+		return errRow(satomic.ErrNilQuerier)
+	}
+	if wq.db == nil {
+		return errRow(satomic.ErrInvalidQuerier)
+	}
+	tx, release, err := wq.acquire()
+	if err != nil {
+		return errRow(err)
+	}
+	defer release()
+	var row *sqlx.Row
+	err = satomic.Intercept(ctx, wq.Querier, "QueryRowxContext", query, args, func(ctx context.Context,
+		query string, args ...interface{}) error {
+		switch {
+		case tx == nil:
+			row = wq.db.QueryRowxContext(ctx, query, args...)
+		case satomic.BufferRows(wq.Querier):
+			// The transaction can be used by other goroutines once it's released, so the row is read first
+			rows, queryErr := tx.QueryContext(ctx, query, args...)
+			if queryErr != nil {
+				row = errRow(queryErr)
+			} else {
+				row = bufferedDB(tx).QueryRowxContext(ctx, "", bufsql.Read(rows))
+			}
+		default:
+			row = tx.QueryRowxContext(ctx, query, args...)
+		}
+		return row.Err()
+	})
+	// The row's error is reported by Scan(). Any other error means the statement wasn't run.
+	if row == nil || (err != nil && !errors.Is(err, row.Err())) {
+		if err == nil {
+			// An Interceptor skipped the statement without an error, so there's no row
+			err = sql.ErrNoRows
+		}
+		return errRow(err)
+	}
+	return row
+}
+
+// errRow returns a *sqlx.Row whose Err() and Scan() return the error
+func errRow(err error) *sqlx.Row {
+	return (&sqlx.Tx{Tx: &sql.Tx{}}).QueryRowxContext(errsql.Context(err), "")
+}
+
+// bufferedDB returns a *sqlx.DB that returns the rows read by bufsql.Read() using the transaction's Mapper
+func bufferedDB(tx *sqlx.Tx) *sqlx.DB {
+	return &sqlx.DB{DB: bufsql.DB(), Mapper: tx.Mapper}
+}
+
+func (wq *wrappedQuerier) Preparex(query string) (*sqlx.Stmt, error) {
+	return wq.PreparexContext(context.Background(), query)
+}
+
+func (wq *wrappedQuerier) PreparexContext(ctx context.Context, query string) (*sqlx.Stmt, error) {
+	if wq == nil {
+		return nil, satomic.ErrNilQuerier
+	}
+	if wq.db == nil {
+		return nil, satomic.ErrInvalidQuerier
+	}
+	tx, release, err := wq.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if tx == nil {
+		return wq.db.PreparexContext(ctx, query)
+	}
+	stmt, err := tx.PreparexContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	wq.stmts.add(stmt)
+	return stmt, nil
+}
+
+func (wq *wrappedQuerier) PrepareNamed(query string) (*sqlx.NamedStmt, error) {
+	return wq.PrepareNamedContext(context.Background(), query)
+}
+
+func (wq *wrappedQuerier) PrepareNamedContext(ctx context.Context, query string) (*sqlx.NamedStmt, error) {
+	if wq == nil {
+		return nil, satomic.ErrNilQuerier
 	}
 	if wq.db == nil {
+		return nil, satomic.ErrInvalidQuerier
+	}
+	tx, release, err := wq.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if tx == nil {
+		return wq.db.PrepareNamedContext(ctx, query)
+	}
+	stmt, err := tx.PrepareNamedContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	wq.stmts.add(stmt)
+	return stmt, nil
+}
+
+func (wq *wrappedQuerier) Txx() *sqlx.Tx {
+	if wq == nil || wq.Querier == nil {
+		return nil
+	}
+	tx := wq.Querier.Tx()
+	if tx == nil {
 		return nil
 	}
-	if wq.tx == nil {
-		return wq.db.QueryRowxContext(ctx, query, args...)
+	if wq.tx != nil && wq.tx.Tx == tx {
+		return wq.tx
 	}
-	return wq.tx.QueryRowxContext(ctx, query, args...)
+	return wq.txs.get(tx)
 }
 
 func (wq *wrappedQuerier) Atomicx(f func(context.Context, Querier) error) *satomic.Error {
-	return wq.Atomic(func(ctx context.Context, q satomic.Querier) error {
-		// Only works b/c the root tx object is set by the TxCreator...
-		// Has the side-effect of running all subsequent queries in a transaction which is not good...
+	return wq.Atomic(wq.wrap(f))
+}
+
+func (wq *wrappedQuerier) AtomicxWithOptions(opts satomic.AtomicOptions,
+	f func(context.Context, Querier) error) *satomic.Error {
+	return wq.AtomicWithOptions(opts, wq.wrap(f))
+}
+
+func (wq *wrappedQuerier) AtomicxNamed(label string, f func(context.Context, Querier) error) *satomic.Error {
+	return wq.AtomicNamed(label, wq.wrap(f))
+}
+
+func (wq *wrappedQuerier) AtomicxWithRetry(policy satomic.RetryPolicy,
+	f func(context.Context, Querier) error) *satomic.Error {
+	return wq.AtomicWithRetry(policy, wq.wrap(f))
+}
+
+// acquire prepares the satomic.Querier for running a statement and returns the *sqlx.Tx to run it in, or nil if the
+// satomic.Querier isn't in a transaction. The returned release function must be called once the statement has been
+// run.
+func (wq *wrappedQuerier) acquire() (*sqlx.Tx, func(), error) {
+	tx, release, err := satomic.AcquireTx(wq.Querier)
+	if err != nil {
+		return nil, nil, err
+	}
+	if wq.tx != nil && (tx == nil || wq.tx.Tx == tx) {
+		return wq.tx, release, nil
+	}
+	if tx == nil {
+		return nil, release, nil
+	}
+	sqlxTx := wq.txs.get(tx)
+	if sqlxTx == nil {
+		// The transaction wasn't created by wq.txCreator()
+		release()
+		return nil, nil, satomic.ErrInvalidQuerier
+	}
+	return sqlxTx, release, nil
+}
+
+// wrap converts the given callback function into one that can be used with the underlying satomic.Querier
+func (wq *wrappedQuerier) wrap(f func(context.Context, Querier) error) func(context.Context, satomic.Querier) error {
+	if f == nil {
+		return nil
+	}
+	return func(ctx context.Context, q satomic.Querier) error {
 		nextWq := *wq
 		nextWq.Querier = q
-		return f(ctx, &nextWq)
-	})
+		nextWq.stmts = &preparedStmts{}
+		defer nextWq.stmts.close()
+		// The transaction is looked up by each statement since it may not have begun yet. See satomic.WithLazyBegin()
+		nextWq.tx = nil
+		return f(satomic.WithQuerier(ctx, &nextWq), &nextWq)
+	}
 }
 
 func (wq *wrappedQuerier) txCreator(ctx context.Context, db *sql.DB, txOpts sql.TxOptions) (*sql.Tx, error) {
@@ -152,19 +418,25 @@ func (wq *wrappedQuerier) txCreator(ctx context.Context, db *sql.DB, txOpts sql.
 	if err != nil {
 		return nil, err
 	}
-	wq.tx = tx
+	wq.txs.add(tx)
+	// Forget the transaction once it ends
+	if q := satomic.QuerierFromContext(ctx, nil); q != nil {
+		q.OnCommit(func(context.Context) { wq.txs.remove(tx.Tx) })
+		q.OnRollback(func(context.Context, error) { wq.txs.remove(tx.Tx) })
+		q.OnCommitOutcomeUnknown(func(context.Context, error) { wq.txs.remove(tx.Tx) })
+	}
 	return tx.Tx, nil
 }
 
 // NewQuerier creates a new Querier
 func NewQuerier(ctx context.Context, db *sqlx.DB, savepointer savepointers.Savepointer,
-	txOpts sql.TxOptions) (Querier, error) {
+	txOpts sql.TxOptions, opts ...satomic.Option) (Querier, error) {
 	if db == nil {
 		return nil, satomic.ErrNeedsDb
 	}
 
-	wq := &wrappedQuerier{db: db}
-	q, err := satomic.NewQuerierWithTxCreator(ctx, db.DB, savepointer, txOpts, wq.txCreator)
+	wq := &wrappedQuerier{db: db, txs: &txRegistry{}}
+	q, err := satomic.NewQuerierWithTxCreator(ctx, db.DB, savepointer, txOpts, wq.txCreator, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -64,10 +64,11 @@ func genWrappedQueriers(t *testing.T) (wqNilDb *wrappedQuerier, nilDbSqlmock sql
 		m.ExpectQuery("").WillReturnRows(genTestRows())
 		return m
 	})
-	_, err := wqWithTx.txCreator(ctx, wqWithTx.db.DB, sql.TxOptions{})
+	tx, err := wqWithTx.txCreator(ctx, wqWithTx.db.DB, sql.TxOptions{})
 	if err != nil {
 		t.Fatal("Could not start transaction:", err)
 	}
+	wqWithTx.tx = wqWithTx.txs.get(tx)
 	return
 }
 
@@ -171,23 +172,23 @@ func TestWrappedQuerierQueryRowx(t *testing.T) {
 	wqNilDb, nilDbSqlmock, wqNilTx, nilTxSqlmock, wqWithTx, withTxSqlmock := genWrappedQueriers(t)
 
 	testCases := []struct {
-		name         string
-		wq           *wrappedQuerier
-		expectNilRow bool
-		_sqlmock     sqlmock.Sqlmock
+		name        string
+		wq          *wrappedQuerier
+		expectedErr error
+		_sqlmock    sqlmock.Sqlmock
 	}{
-		{name: "nil wrappedQuerier", wq: nilWrappedQuerier, expectNilRow: true},
-		{name: "nil db", wq: wqNilDb, expectNilRow: true, _sqlmock: nilDbSqlmock},
-		{name: "nil tx", wq: wqNilTx, expectNilRow: false, _sqlmock: nilTxSqlmock},
-		{name: "with tx", wq: wqWithTx, expectNilRow: false, _sqlmock: withTxSqlmock},
+		{name: "nil wrappedQuerier", wq: nilWrappedQuerier, expectedErr: satomic.ErrNilQuerier},
+		{name: "nil db", wq: wqNilDb, expectedErr: satomic.ErrInvalidQuerier, _sqlmock: nilDbSqlmock},
+		{name: "nil tx", wq: wqNilTx, _sqlmock: nilTxSqlmock},
+		{name: "with tx", wq: wqWithTx, _sqlmock: withTxSqlmock},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			if row := tc.wq.QueryRowx(""); row != nil && tc.expectNilRow {
-				t.Errorf("Expected a nil row but got: %+v", row)
-			} else if row == nil && !tc.expectNilRow {
+			if row := tc.wq.QueryRowx(""); row == nil {
 				t.Error("Got an unxpected nil row")
+			} else if err := row.Err(); err != tc.expectedErr {
+				t.Errorf("Didn't get the expected error: %+v != %+v", err, tc.expectedErr)
 			}
 
 			if tc._sqlmock != nil {
@@ -215,10 +216,11 @@ func TestWrappedQuerierTxCreator(t *testing.T) {
 		m.ExpectBegin()
 		return m
 	})
-	_, err := wqWithTx.txCreator(ctx, wqWithTx.db.DB, sql.TxOptions{})
+	tx, err := wqWithTx.txCreator(ctx, wqWithTx.db.DB, sql.TxOptions{})
 	if err != nil {
 		t.Fatal("Could not start transaction:", err)
 	}
+	wqWithTx.tx = wqWithTx.txs.get(tx)
 
 	beginErr := errors.New("begin err")
 	wqNilTxBeginErr, nilTxBeginErrSqlmock := genWrappedQuerier(t, func(m sqlmock.Sqlmock) sqlmock.Sqlmock {
@@ -3,8 +3,12 @@ package satomicx_test
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"io"
+	"reflect"
+	"sync"
 	"testing"
+	"time"
 )
 
 import (
@@ -14,6 +18,7 @@ import (
 
 import (
 	"github.com/dhui/satomic"
+	"github.com/dhui/satomic/satomictest"
 	"github.com/dhui/satomic/satomicx"
 	"github.com/dhui/satomic/savepointers"
 	"github.com/dhui/satomic/savepointers/mock"
@@ -73,3 +78,506 @@ func TestQuerierBaseImplementers(t *testing.T) { //nolint:revive
 	// Test that sqlx.Tx implements the satomic.QuerierBase interface
 	f(&sqlx.Tx{})
 }
+
+func TestQuerierAtomicxWithRetry(t *testing.T) {
+	db, _sqlmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal("Error creating sqlmock:", err)
+	}
+	defer db.Close() // nolint:errcheck
+
+	serializationErr := errors.New("serialization error")
+	_sqlmock.ExpectBegin()
+	_sqlmock.ExpectQuery("SELECT 1;").WillReturnError(serializationErr)
+	_sqlmock.ExpectRollback()
+	_sqlmock.ExpectBegin()
+	_sqlmock.ExpectQuery("SELECT 1;").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	_sqlmock.ExpectCommit()
+
+	ctx := context.Background()
+	q, err := satomicx.NewQuerier(ctx, sqlx.NewDb(db, ""), mock.NewSavepointer(io.Discard, true), sql.TxOptions{})
+	if err != nil {
+		t.Fatal("Error creating Querier:", err)
+	}
+	policy := satomic.RetryPolicy{MaxAttempts: 2, Retryable: func(err error) bool {
+		return errors.Is(err, serializationErr)
+	}}
+	if err := q.AtomicxWithRetry(policy, func(ctx context.Context, q satomicx.Querier) error {
+		var dest struct{ ID int }
+		return q.GetContext(ctx, &dest, "SELECT 1;")
+	}); err != nil {
+		t.Error(err)
+	}
+
+	if err := _sqlmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQuerierPrepare(t *testing.T) {
+	db, _sqlmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal("Error creating sqlmock:", err)
+	}
+	defer db.Close() // nolint:errcheck
+
+	_sqlmock.ExpectPrepare("SELECT 1;").WillBeClosed()
+	_sqlmock.ExpectBegin()
+	_sqlmock.ExpectPrepare("SELECT 2;").WillBeClosed()
+	_sqlmock.ExpectQuery("SELECT 2;").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	_sqlmock.ExpectPrepare("SELECT 3").WillBeClosed()
+	_sqlmock.ExpectQuery("SELECT 3").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	_sqlmock.ExpectCommit()
+
+	ctx := context.Background()
+	q, err := satomicx.NewQuerier(ctx, sqlx.NewDb(db, "postgres"), mock.NewSavepointer(io.Discard, true),
+		sql.TxOptions{})
+	if err != nil {
+		t.Fatal("Error creating Querier:", err)
+	}
+
+	dbStmt, err := q.PreparexContext(ctx, "SELECT 1;")
+	if err != nil {
+		t.Fatal("Error preparing statement:", err)
+	}
+
+	var txStmt *sqlx.Stmt
+	var txNamedStmt *sqlx.NamedStmt
+	if err := q.Atomicx(func(ctx context.Context, q satomicx.Querier) error {
+		var dest struct{ ID int }
+		if txStmt, err = q.PreparexContext(ctx, "SELECT 2;"); err != nil {
+			return err
+		}
+		if err := txStmt.GetContext(ctx, &dest); err != nil {
+			return err
+		}
+		if txNamedStmt, err = q.PrepareNamedContext(ctx, "SELECT 3 WHERE id = :id"); err != nil {
+			return err
+		}
+		return txNamedStmt.GetContext(ctx, &dest, map[string]interface{}{"id": 3})
+	}); err != nil {
+		t.Error(err)
+	}
+	if _, err := txStmt.Exec(); err == nil {
+		t.Error("Statement prepared within a transaction wasn't closed when the transaction ended")
+	}
+	if err := dbStmt.Close(); err != nil {
+		t.Error(err)
+	}
+
+	if err := _sqlmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQuerierClosed(t *testing.T) {
+	db, _sqlmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal("Error creating sqlmock:", err)
+	}
+	defer db.Close() // nolint:errcheck
+
+	_sqlmock.ExpectBegin()
+	_sqlmock.ExpectCommit()
+
+	ctx := context.Background()
+	q, err := satomicx.NewQuerier(ctx, sqlx.NewDb(db, ""), mock.NewSavepointer(io.Discard, true), sql.TxOptions{})
+	if err != nil {
+		t.Fatal("Error creating Querier:", err)
+	}
+
+	var txQ satomicx.Querier
+	if err := q.Atomicx(func(ctx context.Context, q satomicx.Querier) error {
+		txQ = q
+		return nil
+	}); err != nil {
+		t.Error(err)
+	}
+
+	var dest struct{ ID int }
+	if err := txQ.GetContext(ctx, &dest, "SELECT 1;"); err != satomic.ErrQuerierClosed {
+		t.Errorf("Didn't get the expected GetContext() error: %+v != %+v", err, satomic.ErrQuerierClosed)
+	}
+	if err := txQ.SelectContext(ctx, &dest, "SELECT 1;"); err != satomic.ErrQuerierClosed {
+		t.Errorf("Didn't get the expected SelectContext() error: %+v != %+v", err, satomic.ErrQuerierClosed)
+	}
+	if _, err := txQ.QueryxContext(ctx, "SELECT 1;"); err != satomic.ErrQuerierClosed {
+		t.Errorf("Didn't get the expected QueryxContext() error: %+v != %+v", err, satomic.ErrQuerierClosed)
+	}
+	if err := txQ.QueryRowxContext(ctx, "SELECT 1;").Scan(&dest.ID); err != satomic.ErrQuerierClosed {
+		t.Errorf("Didn't get the expected QueryRowxContext() error: %+v != %+v", err, satomic.ErrQuerierClosed)
+	}
+
+	if err := _sqlmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQuerierConcurrentUse(t *testing.T) {
+	const goroutines = 10
+
+	db, _sqlmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal("Error creating sqlmock:", err)
+	}
+	defer db.Close() // nolint:errcheck
+
+	_sqlmock.MatchExpectationsInOrder(false)
+	for i := 0; i < goroutines; i++ {
+		_sqlmock.ExpectBegin()
+		for j := 0; j < 2; j++ {
+			_sqlmock.ExpectQuery("SELECT 1;").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		}
+		_sqlmock.ExpectCommit()
+	}
+
+	ctx := context.Background()
+	q, err := satomicx.NewQuerier(ctx, sqlx.NewDb(db, "sqlmock"), mock.NewSavepointer(io.Discard, true),
+		sql.TxOptions{}, satomic.WithConcurrentUse())
+	if err != nil {
+		t.Fatal("Error creating Querier:", err)
+	}
+
+	// Transactions are started concurrently and each transaction's Querier is shared by multiple goroutines
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := q.Atomicx(func(ctx context.Context, q satomicx.Querier) error {
+				var txWg sync.WaitGroup
+				for j := 0; j < 2; j++ {
+					txWg.Add(1)
+					go func() {
+						defer txWg.Done()
+						var ids []int
+						if err := q.SelectContext(ctx, &ids, "SELECT 1;"); err != nil {
+							t.Error(err)
+						}
+					}()
+				}
+				txWg.Wait()
+				return nil
+			}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := _sqlmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQuerierConcurrentUseQueries(t *testing.T) {
+	const goroutines = 10
+
+	type row struct {
+		ID   int    `db:"id"`
+		Name string `db:"name"`
+	}
+
+	db, _sqlmock, err := satomictest.NewBusyConnDB()
+	if err != nil {
+		t.Fatal("Error creating sqlmock:", err)
+	}
+	defer db.Close() // nolint:errcheck
+
+	_sqlmock.MatchExpectationsInOrder(false)
+	_sqlmock.ExpectBegin()
+	for i := 0; i < goroutines; i++ {
+		_sqlmock.ExpectExec("UPDATE 1;").WillReturnResult(sqlmock.NewResult(0, 1))
+		_sqlmock.ExpectQuery("SELECT 1;").WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "a").
+			AddRow(2, "b"))
+		_sqlmock.ExpectQuery("SELECT 2;").WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(3, "c"))
+	}
+	_sqlmock.ExpectCommit()
+
+	ctx := context.Background()
+	q, err := satomicx.NewQuerier(ctx, sqlx.NewDb(db, "sqlmock"), mock.NewSavepointer(io.Discard, true),
+		sql.TxOptions{}, satomic.WithConcurrentUse())
+	if err != nil {
+		t.Fatal("Error creating Querier:", err)
+	}
+
+	// Statements run by other goroutines while rows are being read fail with satomictest.ErrConnBusy
+	if err := q.Atomicx(func(ctx context.Context, q satomicx.Querier) error {
+		var wg sync.WaitGroup
+		for i := 0; i < goroutines; i++ {
+			wg.Add(3)
+			go func() {
+				defer wg.Done()
+				if _, err := q.ExecContext(ctx, "UPDATE 1;"); err != nil {
+					t.Error(err)
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				rows, err := q.QueryxContext(ctx, "SELECT 1;")
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				defer rows.Close() // nolint:errcheck
+				var dests []row
+				for rows.Next() {
+					var dest row
+					if err := rows.StructScan(&dest); err != nil {
+						t.Error(err)
+					}
+					dests = append(dests, dest)
+					// Give the other goroutines a chance to run statements while the rows are being read
+					time.Sleep(time.Millisecond)
+				}
+				if err := rows.Err(); err != nil {
+					t.Error(err)
+				}
+				if expected := []row{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}}; !reflect.DeepEqual(dests, expected) {
+					t.Errorf("Didn't get the expected rows: %+v != %+v", dests, expected)
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				var dest row
+				if err := q.QueryRowxContext(ctx, "SELECT 2;").StructScan(&dest); err != nil {
+					t.Error(err)
+				} else if expected := (row{ID: 3, Name: "c"}); dest != expected {
+					t.Errorf("Didn't get the expected row: %+v != %+v", dest, expected)
+				}
+			}()
+		}
+		wg.Wait()
+		return nil
+	}); err != nil {
+		t.Error(err)
+	}
+
+	if err := _sqlmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQuerierTxx(t *testing.T) {
+	db, _sqlmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal("Error creating sqlmock:", err)
+	}
+	defer db.Close() // nolint:errcheck
+
+	_sqlmock.ExpectBegin()
+	_sqlmock.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectExec("RELEASE 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectCommit()
+
+	ctx := context.Background()
+	q, err := satomicx.NewQuerier(ctx, sqlx.NewDb(db, "sqlmock"), mock.NewSavepointer(io.Discard, true),
+		sql.TxOptions{})
+	if err != nil {
+		t.Fatal("Error creating Querier:", err)
+	}
+
+	if tx := q.Txx(); tx != nil {
+		t.Error("Expected a nil *sqlx.Tx but got:", tx)
+	}
+	if err := q.Atomicx(func(ctx context.Context, txQ satomicx.Querier) error {
+		tx := txQ.Txx()
+		if tx == nil || tx.Tx != txQ.Tx() {
+			t.Error("Didn't get the transaction's *sqlx.Tx:", tx)
+		}
+		if err := txQ.Atomicx(func(ctx context.Context, savepointQ satomicx.Querier) error {
+			if savepointQ.Txx() != tx {
+				t.Error("Savepoint didn't get the transaction's *sqlx.Tx:", savepointQ.Txx())
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		return nil
+	}); err != nil {
+		t.Error(err)
+	}
+
+	if err := _sqlmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQuerierAtomicxRequiresNew(t *testing.T) {
+	db, _sqlmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal("Error creating sqlmock:", err)
+	}
+	defer db.Close() // nolint:errcheck
+
+	_sqlmock.ExpectBegin()
+	_sqlmock.ExpectBegin()
+	_sqlmock.ExpectQuery("SELECT 1;").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	_sqlmock.ExpectCommit()
+	_sqlmock.ExpectCommit()
+
+	ctx := context.Background()
+	q, err := satomicx.NewQuerier(ctx, sqlx.NewDb(db, "sqlmock"), mock.NewSavepointer(io.Discard, true),
+		sql.TxOptions{})
+	if err != nil {
+		t.Fatal("Error creating Querier:", err)
+	}
+
+	if err := q.Atomicx(func(ctx context.Context, txQ satomicx.Querier) error {
+		if err := txQ.AtomicxWithOptions(satomic.AtomicOptions{Propagation: satomic.PropagationRequiresNew},
+			func(ctx context.Context, q satomicx.Querier) error {
+				if q.Txx() == nil || q.Txx() == txQ.Txx() {
+					t.Error("Didn't get the new transaction's *sqlx.Tx:", q.Txx())
+				}
+				var id int
+				return q.GetContext(ctx, &id, "SELECT 1;")
+			}); err != nil {
+			return err
+		}
+		return nil
+	}); err != nil {
+		t.Error(err)
+	}
+
+	if err := _sqlmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQuerierLazyBegin(t *testing.T) {
+	db, _sqlmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal("Error creating sqlmock:", err)
+	}
+	defer db.Close() // nolint:errcheck
+
+	_sqlmock.ExpectBegin()
+	_sqlmock.ExpectExec("SAVEPOINT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectQuery("SELECT 1;").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	_sqlmock.ExpectExec("RELEASE 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+	_sqlmock.ExpectCommit()
+
+	ctx := context.Background()
+	q, err := satomicx.NewQuerier(ctx, sqlx.NewDb(db, "sqlmock"), mock.NewSavepointer(io.Discard, true),
+		sql.TxOptions{}, satomic.WithLazyBegin())
+	if err != nil {
+		t.Fatal("Error creating Querier:", err)
+	}
+
+	// No statements, so the transaction isn't begun
+	if err := q.Atomicx(func(ctx context.Context, q satomicx.Querier) error { return nil }); err != nil {
+		t.Error(err)
+	}
+	if err := q.Atomicx(func(ctx context.Context, q satomicx.Querier) error {
+		if err := q.Atomicx(func(ctx context.Context, q satomicx.Querier) error {
+			var id int
+			return q.GetContext(ctx, &id, "SELECT 1;")
+		}); err != nil {
+			return err
+		}
+		return nil
+	}); err != nil {
+		t.Error(err)
+	}
+
+	if err := _sqlmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+type rejectCommitHooks struct {
+	satomic.NoopHooks
+	err error
+}
+
+func (h rejectCommitHooks) BeforeCommit(context.Context, satomic.HookInfo) error { return h.err }
+
+func TestQuerierHooks(t *testing.T) {
+	db, _sqlmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal("Error creating sqlmock:", err)
+	}
+	defer db.Close() // nolint:errcheck
+
+	_sqlmock.ExpectBegin()
+	_sqlmock.ExpectQuery("SELECT 1;").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	_sqlmock.ExpectRollback()
+
+	hookErr := errors.New("hook error")
+	ctx := context.Background()
+	q, err := satomicx.NewQuerier(ctx, sqlx.NewDb(db, "sqlmock"), mock.NewSavepointer(io.Discard, true),
+		sql.TxOptions{}, satomic.WithHooks(rejectCommitHooks{err: hookErr}))
+	if err != nil {
+		t.Fatal("Error creating Querier:", err)
+	}
+
+	if err := q.Atomicx(func(ctx context.Context, q satomicx.Querier) error {
+		var id int
+		return q.GetContext(ctx, &id, "SELECT 1;")
+	}); !errors.Is(err, hookErr) {
+		t.Errorf("Didn't get the expected error: %+v doesn't wrap %v", err, hookErr)
+	}
+
+	if err := _sqlmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQuerierInterceptors(t *testing.T) {
+	db, _sqlmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal("Error creating sqlmock:", err)
+	}
+	defer db.Close() // nolint:errcheck
+
+	_sqlmock.ExpectBegin()
+	_sqlmock.ExpectQuery(`SELECT 1; /\* intercepted \*/`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	_sqlmock.ExpectQuery(`SELECT 2; /\* intercepted \*/`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	_sqlmock.ExpectQuery(`SELECT 3; /\* intercepted \*/`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	_sqlmock.ExpectQuery(`SELECT 4; /\* intercepted \*/`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	_sqlmock.ExpectCommit()
+
+	var methods []string
+	interceptor := func(ctx context.Context, info satomic.StatementInfo, next satomic.StatementRunner) error {
+		if !info.InTransaction || info.Depth != 1 {
+			t.Errorf("Didn't get the expected transaction info: %+v", info)
+		}
+		methods = append(methods, info.Method)
+		return next(ctx, info.Query+" /* intercepted */", info.Args...)
+	}
+
+	ctx := context.Background()
+	q, err := satomicx.NewQuerier(ctx, sqlx.NewDb(db, "sqlmock"), mock.NewSavepointer(io.Discard, true),
+		sql.TxOptions{}, satomic.WithInterceptors(interceptor))
+	if err != nil {
+		t.Fatal("Error creating Querier:", err)
+	}
+
+	if err := q.Atomicx(func(ctx context.Context, q satomicx.Querier) error {
+		var id int
+		if err := q.GetContext(ctx, &id, "SELECT 1;"); err != nil {
+			return err
+		}
+		var ids []int
+		if err := q.SelectContext(ctx, &ids, "SELECT 2;"); err != nil {
+			return err
+		}
+		rows, err := q.QueryxContext(ctx, "SELECT 3;")
+		if err != nil {
+			return err
+		}
+		rows.Close() // nolint:errcheck
+		return q.QueryRowxContext(ctx, "SELECT 4;").Scan(&id)
+	}); err != nil {
+		t.Error(err)
+	}
+
+	expectedMethods := []string{"GetContext", "SelectContext", "QueryxContext", "QueryRowxContext"}
+	if !reflect.DeepEqual(methods, expectedMethods) {
+		t.Errorf("Didn't get the expected methods: %v != %v", methods, expectedMethods)
+	}
+
+	if err := _sqlmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,56 @@
+package savepointers
+
+// ErrorClass is a driver independent category of database errors
+type ErrorClass int
+
+const (
+	// ClassUnknown is used for errors that couldn't be classified
+	ClassUnknown ErrorClass = iota
+	// ClassSerializationFailure is used for errors caused by concurrent transactions that couldn't be serialized
+	ClassSerializationFailure
+	// ClassDeadlock is used for errors caused by the transaction being chosen as a deadlock victim
+	ClassDeadlock
+	// ClassLockTimeout is used for errors caused by waiting too long for a lock
+	ClassLockTimeout
+	// ClassUniqueViolation is used for errors caused by violating a unique or primary key constraint
+	ClassUniqueViolation
+	// ClassForeignKeyViolation is used for errors caused by violating a foreign key constraint
+	ClassForeignKeyViolation
+	// ClassNotNullViolation is used for errors caused by violating a not-null constraint
+	ClassNotNullViolation
+	// ClassCheckViolation is used for errors caused by violating a check constraint
+	ClassCheckViolation
+	// ClassConnectionLost is used for errors caused by the connection to the database being lost
+	ClassConnectionLost
+)
+
+func (c ErrorClass) String() string {
+	switch c {
+	case ClassSerializationFailure:
+		return "serialization failure"
+	case ClassDeadlock:
+		return "deadlock"
+	case ClassLockTimeout:
+		return "lock timeout"
+	case ClassUniqueViolation:
+		return "unique violation"
+	case ClassForeignKeyViolation:
+		return "foreign key violation"
+	case ClassNotNullViolation:
+		return "not-null violation"
+	case ClassCheckViolation:
+		return "check violation"
+	case ClassConnectionLost:
+		return "connection lost"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrorClassifier provides an interface for classifying the errors returned by a database driver.
+// e.g. github.com/dhui/satomic/savepointers/postgres/pqclassify See satomic.WithErrorClassifier()
+type ErrorClassifier interface {
+	// ClassifyError returns the ErrorClass of the given error. ClassUnknown should be returned for errors that
+	// aren't from the ErrorClassifier's driver.
+	ClassifyError(error) ErrorClass
+}
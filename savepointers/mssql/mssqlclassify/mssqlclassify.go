@@ -0,0 +1,48 @@
+// Package mssqlclassify provides an ErrorClassifier for the errors returned by github.com/denisenkom/go-mssqldb. Use it
+// with satomic.WithErrorClassifier(mssqlclassify.Classifier{}). It's separate from the mssql Savepointer package to
+// avoid a dependency on go-mssqldb.
+package mssqlclassify
+
+import (
+	"errors"
+	"strings"
+)
+
+import (
+	mssql "github.com/denisenkom/go-mssqldb"
+)
+
+import (
+	"github.com/dhui/satomic/savepointers"
+)
+
+// Classifier implements the savepointers.ErrorClassifier interface for github.com/denisenkom/go-mssqldb
+type Classifier struct{}
+
+// ClassifyError classifies errors returned by github.com/denisenkom/go-mssqldb
+//
+// https://docs.microsoft.com/en-us/sql/relational-databases/errors-events/database-engine-events-and-errors
+func (c Classifier) ClassifyError(err error) savepointers.ErrorClass {
+	var mssqlErr mssql.Error
+	if !errors.As(err, &mssqlErr) {
+		return savepointers.ClassUnknown
+	}
+	switch mssqlErr.Number {
+	case 3960: // Snapshot isolation transaction aborted due to update conflict
+		return savepointers.ClassSerializationFailure
+	case 1205: // Transaction was deadlocked
+		return savepointers.ClassDeadlock
+	case 1222: // Lock request time out period exceeded
+		return savepointers.ClassLockTimeout
+	case 2601, 2627: // Cannot insert duplicate key row, Violation of PRIMARY KEY or UNIQUE KEY constraint
+		return savepointers.ClassUniqueViolation
+	case 547: // The statement conflicted with a FOREIGN KEY, REFERENCE or CHECK constraint
+		if strings.Contains(mssqlErr.Message, "CHECK constraint") {
+			return savepointers.ClassCheckViolation
+		}
+		return savepointers.ClassForeignKeyViolation
+	case 515: // Cannot insert the value NULL into column
+		return savepointers.ClassNotNullViolation
+	}
+	return savepointers.ClassUnknown
+}
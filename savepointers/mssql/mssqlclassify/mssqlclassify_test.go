@@ -0,0 +1,52 @@
+package mssqlclassify_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+import (
+	mssqldriver "github.com/denisenkom/go-mssqldb"
+)
+
+import (
+	"github.com/dhui/satomic/savepointers"
+	"github.com/dhui/satomic/savepointers/mssql/mssqlclassify"
+)
+
+func TestClassifierClassifyError(t *testing.T) {
+	testCases := []struct {
+		name          string
+		err           error
+		expectedClass savepointers.ErrorClass
+	}{
+		{name: "nil", err: nil, expectedClass: savepointers.ClassUnknown},
+		{name: "not mssql", err: errors.New("not mssql"), expectedClass: savepointers.ClassUnknown},
+		{name: "unknown number", err: mssqldriver.Error{Number: 102}, expectedClass: savepointers.ClassUnknown},
+		{name: "serialization failure", err: mssqldriver.Error{Number: 3960},
+			expectedClass: savepointers.ClassSerializationFailure},
+		{name: "deadlock", err: mssqldriver.Error{Number: 1205}, expectedClass: savepointers.ClassDeadlock},
+		{name: "lock timeout", err: mssqldriver.Error{Number: 1222}, expectedClass: savepointers.ClassLockTimeout},
+		{name: "unique violation", err: mssqldriver.Error{Number: 2627},
+			expectedClass: savepointers.ClassUniqueViolation},
+		{name: "foreign key violation", err: mssqldriver.Error{Number: 547,
+			Message: `The INSERT statement conflicted with the FOREIGN KEY constraint "fk".`},
+			expectedClass: savepointers.ClassForeignKeyViolation},
+		{name: "check violation", err: mssqldriver.Error{Number: 547,
+			Message: `The INSERT statement conflicted with the CHECK constraint "ck".`},
+			expectedClass: savepointers.ClassCheckViolation},
+		{name: "not-null violation", err: mssqldriver.Error{Number: 515},
+			expectedClass: savepointers.ClassNotNullViolation},
+		{name: "wrapped", err: fmt.Errorf("wrapped: %w", mssqldriver.Error{Number: 1205}),
+			expectedClass: savepointers.ClassDeadlock},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if class := (mssqlclassify.Classifier{}).ClassifyError(tc.err); class != tc.expectedClass {
+				t.Errorf("Didn't get the expected class: %v != %v", class, tc.expectedClass)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+// Package mysqlclassify provides an ErrorClassifier for the errors returned by github.com/go-sql-driver/mysql. Use it
+// with satomic.WithErrorClassifier(mysqlclassify.Classifier{}). It's separate from the mysql Savepointer package to
+// avoid a dependency on mysql.
+package mysqlclassify
+
+import (
+	"errors"
+)
+
+import (
+	"github.com/go-sql-driver/mysql"
+)
+
+import (
+	"github.com/dhui/satomic/savepointers"
+)
+
+// Classifier implements the savepointers.ErrorClassifier interface for github.com/go-sql-driver/mysql
+type Classifier struct{}
+
+// ClassifyError classifies errors returned by github.com/go-sql-driver/mysql
+//
+// https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html
+func (c Classifier) ClassifyError(err error) savepointers.ErrorClass {
+	if errors.Is(err, mysql.ErrInvalidConn) {
+		return savepointers.ClassConnectionLost
+	}
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return savepointers.ClassUnknown
+	}
+	switch mysqlErr.Number {
+	case 1213: // ER_LOCK_DEADLOCK
+		return savepointers.ClassDeadlock
+	case 1205: // ER_LOCK_WAIT_TIMEOUT
+		return savepointers.ClassLockTimeout
+	case 1062, 1586: // ER_DUP_ENTRY, ER_DUP_ENTRY_WITH_KEY_NAME
+		return savepointers.ClassUniqueViolation
+	// ER_NO_REFERENCED_ROW, ER_ROW_IS_REFERENCED, ER_ROW_IS_REFERENCED_2, ER_NO_REFERENCED_ROW_2
+	case 1216, 1217, 1451, 1452:
+		return savepointers.ClassForeignKeyViolation
+	case 1048: // ER_BAD_NULL_ERROR
+		return savepointers.ClassNotNullViolation
+	case 3819: // ER_CHECK_CONSTRAINT_VIOLATED
+		return savepointers.ClassCheckViolation
+	case 1053, 2006, 2013: // ER_SERVER_SHUTDOWN, CR_SERVER_GONE_ERROR, CR_SERVER_LOST
+		return savepointers.ClassConnectionLost
+	}
+	return savepointers.ClassUnknown
+}
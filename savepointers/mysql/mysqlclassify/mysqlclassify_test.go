@@ -0,0 +1,53 @@
+package mysqlclassify_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+import (
+	mysqldriver "github.com/go-sql-driver/mysql"
+)
+
+import (
+	"github.com/dhui/satomic/savepointers"
+	"github.com/dhui/satomic/savepointers/mysql/mysqlclassify"
+)
+
+func TestClassifierClassifyError(t *testing.T) {
+	testCases := []struct {
+		name          string
+		err           error
+		expectedClass savepointers.ErrorClass
+	}{
+		{name: "nil", err: nil, expectedClass: savepointers.ClassUnknown},
+		{name: "not mysql", err: errors.New("not mysql"), expectedClass: savepointers.ClassUnknown},
+		{name: "unknown number", err: &mysqldriver.MySQLError{Number: 1064},
+			expectedClass: savepointers.ClassUnknown},
+		{name: "deadlock", err: &mysqldriver.MySQLError{Number: 1213}, expectedClass: savepointers.ClassDeadlock},
+		{name: "lock timeout", err: &mysqldriver.MySQLError{Number: 1205},
+			expectedClass: savepointers.ClassLockTimeout},
+		{name: "unique violation", err: &mysqldriver.MySQLError{Number: 1062},
+			expectedClass: savepointers.ClassUniqueViolation},
+		{name: "foreign key violation", err: &mysqldriver.MySQLError{Number: 1452},
+			expectedClass: savepointers.ClassForeignKeyViolation},
+		{name: "not-null violation", err: &mysqldriver.MySQLError{Number: 1048},
+			expectedClass: savepointers.ClassNotNullViolation},
+		{name: "check violation", err: &mysqldriver.MySQLError{Number: 3819},
+			expectedClass: savepointers.ClassCheckViolation},
+		{name: "server gone", err: &mysqldriver.MySQLError{Number: 2006},
+			expectedClass: savepointers.ClassConnectionLost},
+		{name: "invalid conn", err: mysqldriver.ErrInvalidConn, expectedClass: savepointers.ClassConnectionLost},
+		{name: "wrapped", err: fmt.Errorf("wrapped: %w", &mysqldriver.MySQLError{Number: 1213}),
+			expectedClass: savepointers.ClassDeadlock},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if class := (mysqlclassify.Classifier{}).ClassifyError(tc.err); class != tc.expectedClass {
+				t.Errorf("Didn't get the expected class: %v != %v", class, tc.expectedClass)
+			}
+		})
+	}
+}
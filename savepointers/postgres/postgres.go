@@ -2,9 +2,21 @@
 package postgres
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"strings"
 )
 
+var (
+	// ErrTxInProgress is the canonical error value when a transaction's commit can't be verified because the
+	// transaction is still in progress
+	ErrTxInProgress = errors.New("Transaction is still in progress")
+	// ErrTxStatusUnavailable is the canonical error value when a transaction's commit can't be verified because
+	// Postgres no longer has the transaction's status. e.g. the transaction is too old
+	ErrTxStatusUnavailable = errors.New("Transaction status is unavailable")
+)
+
 // Quote quotes the given Postgres identifier
 //
 // https://www.postgresql.org/docs/current/sql-syntax-lexical.html#SQL-SYNTAX-IDENTIFIERS
@@ -37,3 +49,36 @@ func (sp Savepointer) Rollback(name string) string {
 func (sp Savepointer) Release(name string) string {
 	return "RELEASE " + Quote(name) + ";"
 }
+
+// CommitVerifier implements the satomic.CommitVerifier interface for Postgres using txid_current() and
+// txid_status(). Requires Postgres 10+
+//
+// https://www.postgresql.org/docs/current/functions-info.html#FUNCTIONS-INFO-SNAPSHOT
+type CommitVerifier struct{}
+
+// TxID returns the transaction's txid, assigning one if the transaction doesn't have one yet
+func (v CommitVerifier) TxID(ctx context.Context, tx *sql.Tx) (int64, error) {
+	var txID int64
+	if err := tx.QueryRowContext(ctx, "SELECT txid_current();").Scan(&txID); err != nil {
+		return 0, err
+	}
+	return txID, nil
+}
+
+// Committed determines whether or not the transaction with the given txid was committed
+func (v CommitVerifier) Committed(ctx context.Context, db *sql.DB, txID int64) (bool, error) {
+	var status sql.NullString
+	if err := db.QueryRowContext(ctx, "SELECT txid_status($1);", txID).Scan(&status); err != nil {
+		return false, err
+	}
+	switch status.String {
+	case "committed":
+		return true, nil
+	case "aborted":
+		return false, nil
+	case "in progress":
+		return false, ErrTxInProgress
+	default:
+		return false, ErrTxStatusUnavailable
+	}
+}
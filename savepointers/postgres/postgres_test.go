@@ -3,12 +3,14 @@ package postgres_test
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
 )
 
 import (
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
 	"github.com/dhui/dktest"
 	_ "github.com/lib/pq"
 )
@@ -61,3 +63,67 @@ func TestSavepointerPostgres(t *testing.T) {
 		},
 		postgresDBGetter)
 }
+
+func TestCommitVerifier(t *testing.T) {
+	dbErr := errors.New("db error")
+
+	testCases := []struct {
+		name              string
+		status            interface{}
+		err               error
+		expectedCommitted bool
+		expectedErr       error
+	}{
+		{name: "committed", status: "committed", expectedCommitted: true},
+		{name: "aborted", status: "aborted", expectedCommitted: false},
+		{name: "in progress", status: "in progress", expectedErr: postgres.ErrTxInProgress},
+		{name: "unavailable", status: nil, expectedErr: postgres.ErrTxStatusUnavailable},
+		{name: "error", err: dbErr, expectedErr: dbErr},
+	}
+
+	ctx := context.Background()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, _sqlmock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal("Error creating sqlmock:", err)
+			}
+			defer db.Close() // nolint:errcheck
+
+			_sqlmock.ExpectBegin()
+			_sqlmock.ExpectQuery(`SELECT txid_current\(\);`).WillReturnRows(
+				sqlmock.NewRows([]string{"txid_current"}).AddRow(int64(42)))
+			_sqlmock.ExpectRollback()
+			statusQuery := _sqlmock.ExpectQuery(`SELECT txid_status\(\$1\);`).WithArgs(int64(42))
+			if tc.err != nil {
+				statusQuery.WillReturnError(tc.err)
+			} else {
+				statusQuery.WillReturnRows(sqlmock.NewRows([]string{"txid_status"}).AddRow(tc.status))
+			}
+
+			tx, err := db.BeginTx(ctx, nil)
+			if err != nil {
+				t.Fatal("Error beginning transaction:", err)
+			}
+			txID, err := (postgres.CommitVerifier{}).TxID(ctx, tx)
+			if err != nil {
+				t.Fatal("Error getting txid:", err)
+			}
+			if err := tx.Rollback(); err != nil {
+				t.Fatal("Error rolling back transaction:", err)
+			}
+
+			committed, err := (postgres.CommitVerifier{}).Committed(ctx, db, txID)
+			if !errors.Is(err, tc.expectedErr) {
+				t.Errorf("Didn't get the expected error: %v != %v", err, tc.expectedErr)
+			}
+			if committed != tc.expectedCommitted {
+				t.Errorf("Didn't get the expected committed: %v != %v", committed, tc.expectedCommitted)
+			}
+
+			if err := _sqlmock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+// Package pqclassify provides an ErrorClassifier for the errors returned by github.com/lib/pq. Use it with
+// satomic.WithErrorClassifier(pqclassify.Classifier{}). It's separate from the postgres Savepointer package to avoid a
+// dependency on pq.
+package pqclassify
+
+import (
+	"errors"
+)
+
+import (
+	"github.com/lib/pq"
+)
+
+import (
+	"github.com/dhui/satomic/savepointers"
+)
+
+// Classifier implements the savepointers.ErrorClassifier interface for github.com/lib/pq
+type Classifier struct{}
+
+// ClassifyError classifies errors returned by github.com/lib/pq
+//
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+func (c Classifier) ClassifyError(err error) savepointers.ErrorClass {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return savepointers.ClassUnknown
+	}
+	switch pqErr.Code {
+	case "40001": // serialization_failure
+		return savepointers.ClassSerializationFailure
+	case "40P01": // deadlock_detected
+		return savepointers.ClassDeadlock
+	case "55P03": // lock_not_available
+		return savepointers.ClassLockTimeout
+	case "23505": // unique_violation
+		return savepointers.ClassUniqueViolation
+	case "23503": // foreign_key_violation
+		return savepointers.ClassForeignKeyViolation
+	case "23502": // not_null_violation
+		return savepointers.ClassNotNullViolation
+	case "23514": // check_violation
+		return savepointers.ClassCheckViolation
+	case "57P01", "57P02", "57P03": // admin_shutdown, crash_shutdown, cannot_connect_now
+		return savepointers.ClassConnectionLost
+	}
+	if pqErr.Code.Class() == "08" { // connection_exception
+		return savepointers.ClassConnectionLost
+	}
+	return savepointers.ClassUnknown
+}
@@ -0,0 +1,51 @@
+package pqclassify_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+import (
+	"github.com/lib/pq"
+)
+
+import (
+	"github.com/dhui/satomic/savepointers"
+	"github.com/dhui/satomic/savepointers/postgres/pqclassify"
+)
+
+func TestClassifierClassifyError(t *testing.T) {
+	testCases := []struct {
+		name          string
+		err           error
+		expectedClass savepointers.ErrorClass
+	}{
+		{name: "nil", err: nil, expectedClass: savepointers.ClassUnknown},
+		{name: "not pq", err: errors.New("not pq"), expectedClass: savepointers.ClassUnknown},
+		{name: "unknown code", err: &pq.Error{Code: "42601"}, expectedClass: savepointers.ClassUnknown},
+		{name: "serialization failure", err: &pq.Error{Code: "40001"},
+			expectedClass: savepointers.ClassSerializationFailure},
+		{name: "deadlock", err: &pq.Error{Code: "40P01"}, expectedClass: savepointers.ClassDeadlock},
+		{name: "lock timeout", err: &pq.Error{Code: "55P03"}, expectedClass: savepointers.ClassLockTimeout},
+		{name: "unique violation", err: &pq.Error{Code: "23505"}, expectedClass: savepointers.ClassUniqueViolation},
+		{name: "foreign key violation", err: &pq.Error{Code: "23503"},
+			expectedClass: savepointers.ClassForeignKeyViolation},
+		{name: "not-null violation", err: &pq.Error{Code: "23502"},
+			expectedClass: savepointers.ClassNotNullViolation},
+		{name: "check violation", err: &pq.Error{Code: "23514"}, expectedClass: savepointers.ClassCheckViolation},
+		{name: "connection exception", err: &pq.Error{Code: "08006"},
+			expectedClass: savepointers.ClassConnectionLost},
+		{name: "admin shutdown", err: &pq.Error{Code: "57P01"}, expectedClass: savepointers.ClassConnectionLost},
+		{name: "wrapped", err: fmt.Errorf("wrapped: %w", &pq.Error{Code: "40001"}),
+			expectedClass: savepointers.ClassSerializationFailure},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if class := (pqclassify.Classifier{}).ClassifyError(tc.err); class != tc.expectedClass {
+				t.Errorf("Didn't get the expected class: %v != %v", class, tc.expectedClass)
+			}
+		})
+	}
+}
@@ -3,12 +3,22 @@ package savepointers
 import (
 	"encoding/base64"
 	"encoding/binary"
+	"encoding/hex"
 	"math/rand/v2"
+	"strings"
 )
 
 // number of bytes used to generate a random savepoint name. 16 bytes is plenty since it's the same size as a uuid
 const savepointNumBytes = 16
 
+// number of random bytes used as the suffix of a labeled savepoint name. The suffix only needs to be unique amongst
+// the savepoints of a single transaction.
+const labeledSavepointNumBytes = 4
+
+// MaxLabeledSavepointNameLen is the maximum length of a savepoint name generated by GenLabeledSavepointName().
+// MS SQL only uses the first 32 characters of a savepoint name.
+const MaxLabeledSavepointNameLen = 32
+
 // GenSavepointName quickly generates a unique savepoint name
 func GenSavepointName() string {
 	b := make([]byte, savepointNumBytes)
@@ -20,3 +30,30 @@ func GenSavepointName() string {
 
 	return base64.RawStdEncoding.EncodeToString(b)
 }
+
+// GenLabeledSavepointName generates a readable savepoint name from the label followed by a random suffix to keep it
+// unique. Characters other than ASCII letters, digits and underscores are replaced with underscores and the start of
+// long labels is trimmed so that the name is at most MaxLabeledSavepointNameLen characters.
+// e.g. "checkout_reserve_stock_1f2e3d4c" for the label "checkout/reserve-stock".
+// If the label is empty, GenSavepointName() is used.
+func GenLabeledSavepointName(label string) string {
+	if label == "" {
+		return GenSavepointName()
+	}
+	b := make([]byte, labeledSavepointNumBytes)
+	// ignore gosec G404 - suggesting crypto/rand over math/rand/v2
+	binary.NativeEndian.PutUint32(b, rand.Uint32()) //nolint:gosec
+	suffix := "_" + hex.EncodeToString(b)
+
+	name := strings.Map(func(r rune) rune {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, label)
+	if maxLen := MaxLabeledSavepointNameLen - len(suffix); len(name) > maxLen {
+		// keep the end of the label since it's the most specific part of the label path
+		name = name[len(name)-maxLen:]
+	}
+	return name + suffix
+}
@@ -1,6 +1,7 @@
 package savepointers_test
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -15,3 +16,37 @@ func TestGenSavepointName(t *testing.T) {
 			expectedLen)
 	}
 }
+
+func TestGenLabeledSavepointName(t *testing.T) {
+	testCases := []struct {
+		label          string
+		expectedPrefix string
+	}{
+		{label: "checkout", expectedPrefix: "checkout_"},
+		{label: "checkout/reserve-stock", expectedPrefix: "checkout_reserve_stock_"},
+		{label: `a"; DROP TABLE b; --`, expectedPrefix: "a___DROP_TABLE_b_____"},
+		{label: "checkout/reserve-stock/decrement", expectedPrefix: "reserve_stock_decrement_"},
+		{label: "héllo", expectedPrefix: "h_llo_"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.label, func(t *testing.T) {
+			name := savepointers.GenLabeledSavepointName(tc.label)
+			if !strings.HasPrefix(name, tc.expectedPrefix) {
+				t.Errorf("Generated savepoint name doesn't have the expected prefix: %q doesn't start with %q", name,
+					tc.expectedPrefix)
+			}
+			if expectedLen := min(len(tc.expectedPrefix)+8, savepointers.MaxLabeledSavepointNameLen); len(name) !=
+				expectedLen {
+				t.Error("Generated savepoint name doesn't have the expected length:", len(name), "!=", expectedLen)
+			}
+			if name == savepointers.GenLabeledSavepointName(tc.label) {
+				t.Error("Generated savepoint names aren't unique:", name)
+			}
+		})
+	}
+
+	if name := savepointers.GenLabeledSavepointName(""); len(name) != 22 {
+		t.Error("Generated savepoint name for an empty label isn't a random savepoint name:", name)
+	}
+}
@@ -0,0 +1,6 @@
+// Package sqlite3classify provides an ErrorClassifier for the errors returned by github.com/mattn/go-sqlite3. Use it
+// with satomic.WithErrorClassifier(sqlite3classify.Classifier{}). It's separate from the sqlite Savepointer package to
+// avoid a dependency on go-sqlite3.
+//
+// go-sqlite3 requires cgo, so the ErrorClassifier isn't available when cgo is disabled.
+package sqlite3classify
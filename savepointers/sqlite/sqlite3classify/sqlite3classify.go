@@ -0,0 +1,45 @@
+//go:build cgo
+
+package sqlite3classify
+
+import (
+	"errors"
+)
+
+import (
+	"github.com/mattn/go-sqlite3"
+)
+
+import (
+	"github.com/dhui/satomic/savepointers"
+)
+
+// Classifier implements the savepointers.ErrorClassifier interface for github.com/mattn/go-sqlite3
+type Classifier struct{}
+
+// ClassifyError classifies errors returned by github.com/mattn/go-sqlite3
+//
+// https://www.sqlite.org/rescode.html
+func (c Classifier) ClassifyError(err error) savepointers.ErrorClass {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return savepointers.ClassUnknown
+	}
+	switch sqliteErr.ExtendedCode {
+	case sqlite3.ErrBusySnapshot:
+		return savepointers.ClassSerializationFailure
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		return savepointers.ClassUniqueViolation
+	case sqlite3.ErrConstraintForeignKey:
+		return savepointers.ClassForeignKeyViolation
+	case sqlite3.ErrConstraintNotNull:
+		return savepointers.ClassNotNullViolation
+	case sqlite3.ErrConstraintCheck:
+		return savepointers.ClassCheckViolation
+	}
+	switch sqliteErr.Code {
+	case sqlite3.ErrBusy, sqlite3.ErrLocked:
+		return savepointers.ClassLockTimeout
+	}
+	return savepointers.ClassUnknown
+}
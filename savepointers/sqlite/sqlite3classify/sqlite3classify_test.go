@@ -0,0 +1,54 @@
+//go:build cgo
+
+package sqlite3classify_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+import (
+	"github.com/mattn/go-sqlite3"
+)
+
+import (
+	"github.com/dhui/satomic/savepointers"
+	"github.com/dhui/satomic/savepointers/sqlite/sqlite3classify"
+)
+
+func TestClassifierClassifyError(t *testing.T) {
+	testCases := []struct {
+		name          string
+		err           error
+		expectedClass savepointers.ErrorClass
+	}{
+		{name: "nil", err: nil, expectedClass: savepointers.ClassUnknown},
+		{name: "not sqlite", err: errors.New("not sqlite"), expectedClass: savepointers.ClassUnknown},
+		{name: "unknown code", err: sqlite3.Error{Code: sqlite3.ErrError}, expectedClass: savepointers.ClassUnknown},
+		{name: "busy snapshot", err: sqlite3.Error{Code: sqlite3.ErrBusy, ExtendedCode: sqlite3.ErrBusySnapshot},
+			expectedClass: savepointers.ClassSerializationFailure},
+		{name: "busy", err: sqlite3.Error{Code: sqlite3.ErrBusy}, expectedClass: savepointers.ClassLockTimeout},
+		{name: "locked", err: sqlite3.Error{Code: sqlite3.ErrLocked}, expectedClass: savepointers.ClassLockTimeout},
+		{name: "unique violation", err: sqlite3.Error{Code: sqlite3.ErrConstraint,
+			ExtendedCode: sqlite3.ErrConstraintUnique}, expectedClass: savepointers.ClassUniqueViolation},
+		{name: "primary key violation", err: sqlite3.Error{Code: sqlite3.ErrConstraint,
+			ExtendedCode: sqlite3.ErrConstraintPrimaryKey}, expectedClass: savepointers.ClassUniqueViolation},
+		{name: "foreign key violation", err: sqlite3.Error{Code: sqlite3.ErrConstraint,
+			ExtendedCode: sqlite3.ErrConstraintForeignKey}, expectedClass: savepointers.ClassForeignKeyViolation},
+		{name: "not-null violation", err: sqlite3.Error{Code: sqlite3.ErrConstraint,
+			ExtendedCode: sqlite3.ErrConstraintNotNull}, expectedClass: savepointers.ClassNotNullViolation},
+		{name: "check violation", err: sqlite3.Error{Code: sqlite3.ErrConstraint,
+			ExtendedCode: sqlite3.ErrConstraintCheck}, expectedClass: savepointers.ClassCheckViolation},
+		{name: "wrapped", err: fmt.Errorf("wrapped: %w", sqlite3.Error{Code: sqlite3.ErrBusy}),
+			expectedClass: savepointers.ClassLockTimeout},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if class := (sqlite3classify.Classifier{}).ClassifyError(tc.err); class != tc.expectedClass {
+				t.Errorf("Didn't get the expected class: %v != %v", class, tc.expectedClass)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package satomic
+
+import (
+	"database/sql"
+	"sync"
+	"sync/atomic"
+)
+
+// scope holds the state of a single transaction or savepoint created by Atomic(). It's shared by the copies of the
+// Querier passed to the callback function.
+type scope struct {
+	// parent is the scope of the enclosing transaction or savepoint. nil for a transaction.
+	parent *scope
+	// joined is set if the scope joined its parent's transaction or savepoint instead of creating a savepoint
+	joined bool
+	// start begins the scope's transaction or creates its savepoint and returns the transaction
+	start func() (*sql.Tx, error)
+	// txMu guards tx
+	txMu sync.Mutex
+	// tx is set once the scope's transaction has begun and its savepoint, if any, has been created
+	tx        *sql.Tx
+	callbacks callbacks
+	// mu serializes the use of the scope's Querier when it's created with WithConcurrentUse()
+	mu sync.Mutex
+	// stmtsMu guards stmts and txStmts
+	stmtsMu sync.Mutex
+	// stmts are the statements prepared within the scope
+	stmts []*sql.Stmt
+	// txStmts maps statements prepared on the DB to the statements bound to the transaction.
+	// Only used by the transaction's scope.
+	txStmts map[*sql.Stmt]*sql.Stmt
+	// closed is set once the transaction or savepoint ends
+	closed atomic.Bool
+	// active is set while a nested savepoint is active
+	active atomic.Bool
+	// rollbackOnly is set if the transaction or savepoint must be rolled back
+	rollbackOnly atomic.Bool
+	// txID identifies the transaction when it's verifiable. Only used by the transaction's scope.
+	// See WithCommitVerifier()
+	txID       int64
+	verifiable bool
+}
+
+func newScope(parent *scope) *scope {
+	return &scope{parent: parent}
+}
+
+// ensureTx begins the scope's transaction or creates its savepoint if it hasn't been already and returns the
+// transaction
+func (s *scope) ensureTx() (*sql.Tx, error) {
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+	if s.tx != nil {
+		return s.tx, nil
+	}
+	tx, err := s.start()
+	if err != nil {
+		return nil, err
+	}
+	s.tx = tx
+	return tx, nil
+}
+
+// currentTx returns the scope's transaction or nil if it hasn't begun or the scope's savepoint hasn't been created
+func (s *scope) currentTx() *sql.Tx {
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+	return s.tx
+}
+
+// savepoint returns the scope of the transaction or savepoint that the scope is in, skipping any joined scopes
+func (s *scope) savepoint() *scope {
+	for s.joined {
+		s = s.parent
+	}
+	return s
+}
+
+// root returns the transaction's scope
+func (s *scope) root() *scope {
+	for s.parent != nil {
+		s = s.parent
+	}
+	return s
+}
+
+// err returns an error if the scope's Querier can't be used. A nil scope, i.e. one that isn't in a transaction,
+// can always be used.
+func (s *scope) err() error {
+	if s == nil {
+		return nil
+	}
+	if s.closed.Load() {
+		return ErrQuerierClosed
+	}
+	if s.active.Load() {
+		return ErrNestedQuerierActive
+	}
+	return nil
+}
+
+// close marks the scope's transaction or savepoint as ended once the statements being run by the scope's Querier
+// have completed
+func (s *scope) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed.Store(true)
+}
+
+// setActive marks whether or not a nested savepoint is active
+func (s *scope) setActive(active bool) {
+	if s != nil {
+		s.active.Store(active)
+	}
+}
+
+// promote moves the callbacks from a released savepoint's scope to its parent scope
+func (s *scope) promote(child *scope) {
+	if s == nil {
+		return
+	}
+	s.callbacks.promote(&child.callbacks)
+}
+
+// addStmt tracks a statement prepared within the scope so that it's closed when the scope ends
+func (s *scope) addStmt(stmt *sql.Stmt) {
+	s.stmtsMu.Lock()
+	defer s.stmtsMu.Unlock()
+	s.stmts = append(s.stmts, stmt)
+}
+
+// txStmt returns the statement bound to the transaction for the given statement prepared on the DB, binding it with
+// the given function if needed. Only used with the transaction's scope.
+func (s *scope) txStmt(stmt *sql.Stmt, bind func(*sql.Stmt) *sql.Stmt) *sql.Stmt {
+	s.stmtsMu.Lock()
+	defer s.stmtsMu.Unlock()
+	if txStmt, ok := s.txStmts[stmt]; ok {
+		return txStmt
+	}
+	txStmt := bind(stmt)
+	if s.txStmts == nil {
+		s.txStmts = map[*sql.Stmt]*sql.Stmt{}
+	}
+	s.txStmts[stmt] = txStmt
+	return txStmt
+}
+
+// closeStmts closes the statements prepared within the scope, including any statements bound to the transaction
+func (s *scope) closeStmts() {
+	s.stmtsMu.Lock()
+	defer s.stmtsMu.Unlock()
+	for _, stmt := range s.stmts {
+		stmt.Close() // nolint:errcheck
+	}
+	s.stmts = nil
+	for _, stmt := range s.txStmts {
+		stmt.Close() // nolint:errcheck
+	}
+	s.txStmts = nil
+}